@@ -0,0 +1,220 @@
+package api
+
+import (
+	"alert-mobile-notify/contact"
+	"alert-mobile-notify/ec600n"
+	"alert-mobile-notify/job"
+	"alert-mobile-notify/notification"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// AckMethodSMS 通过回复短信确认
+	AckMethodSMS = "sms"
+	// AckMethodCallback 通过回拨电话确认
+	AckMethodCallback = "callback"
+	// AckMethodDTMF 通过通话中按键确认
+	AckMethodDTMF = "dtmf"
+	// DefaultDTMFKey 默认确认按键
+	DefaultDTMFKey = "1"
+)
+
+// DefaultAckKeywords 默认确认短信关键字
+var DefaultAckKeywords = []string{"ACK", "1"}
+
+// ackMethodText 确认方式描述
+var ackMethodText = map[string]string{
+	AckMethodSMS:      "回复短信",
+	AckMethodCallback: "回拨电话",
+	AckMethodDTMF:     "通话中按键",
+}
+
+// callRun 正在执行的拨号任务
+type callRun struct {
+	jobID   string
+	name    string
+	numbers []string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	ack       *job.Ack
+	cancelled bool
+}
+
+// newCallRun 创建拨号任务，parent 取消时任务随之停止
+func newCallRun(parent context.Context, name string, numbers []string) *callRun {
+	ctx, cancel := context.WithCancel(parent)
+	return &callRun{name: name, numbers: numbers, ctx: ctx, cancel: cancel}
+}
+
+// acknowledge 标记任务已确认并停止后续拨号，重复确认返回 false
+func (r *callRun) acknowledge(number, method string) (*job.Ack, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ack != nil {
+		return r.ack, false
+	}
+	r.ack = &job.Ack{Number: number, Method: method, At: time.Now()}
+	r.cancel()
+	return r.ack, true
+}
+
+// acked 返回确认信息，未确认时为 nil
+func (r *callRun) acked() *job.Ack {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ack
+}
+
+// abort 取消任务并停止后续拨号
+func (r *callRun) abort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cancelled = true
+	r.cancel()
+}
+
+// aborted 任务是否已被取消
+func (r *callRun) aborted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled
+}
+
+// matchNumber 返回任务号码列表中与 number 相同的号码
+func (r *callRun) matchNumber(number string) (string, bool) {
+	for _, n := range r.numbers {
+		if samePhoneNumber(n, number) {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// currentRun 返回正在执行的拨号任务
+func (s *HTTPServer) currentRun() *callRun {
+	s.callMu.Lock()
+	defer s.callMu.Unlock()
+	return s.current
+}
+
+// watchAcks 监听确认短信与来电，确认当前拨号任务
+// 返回的函数用于停止监听
+func (s *HTTPServer) watchAcks() func() {
+	events, unsubscribe := s.ec600n.Subscribe(ec600n.EventSMSReceived, ec600n.EventCallerID)
+
+	go func() {
+		for ev := range events {
+			switch ev.Type {
+			case ec600n.EventSMSReceived:
+				if ev.Message != nil && s.isAckMessage(ev.Message.Text) {
+					s.handleAck(ev.Number, AckMethodSMS)
+				}
+			case ec600n.EventCallerID:
+				s.handleAck(ev.Number, AckMethodCallback)
+			}
+		}
+	}()
+
+	return unsubscribe
+}
+
+// handleAck 处理来自 number 的确认，号码需在当前任务的号码列表中
+func (s *HTTPServer) handleAck(number, method string) {
+	run := s.currentRun()
+	if run == nil {
+		return
+	}
+
+	matched, ok := run.matchNumber(number)
+	if !ok {
+		zap.S().Infof("忽略非当前任务号码的确认: %s (%s)", number, method)
+		return
+	}
+
+	ack, first := run.acknowledge(matched, method)
+	if method == AckMethodCallback {
+		// 拒接确认来电，避免持续振铃
+		if err := s.ec600n.HangupCall(); err != nil {
+			zap.S().Errorf("挂断确认来电失败: %v", err)
+		}
+	}
+	if first {
+		s.onAcknowledged(run, ack)
+	}
+}
+
+// onAcknowledged 告警首次确认后记录日志并发送通知
+func (s *HTTPServer) onAcknowledged(run *callRun, ack *job.Ack) {
+	zap.S().Infof("告警已确认: name=%s, number=%s, method=%s", run.name, ack.Number, ack.Method)
+	if run.jobID != "" {
+		if err := s.queue.SetAck(run.jobID, ack); err != nil {
+			zap.S().Errorf("记录任务确认信息失败: %v", err)
+		}
+	}
+	s.sendAckNotification(run.name, ack)
+}
+
+// dtmfConfirmKey 返回通话中的确认按键，未开启按键确认时返回空
+func (s *HTTPServer) dtmfConfirmKey() string {
+	if !s.config.EC600N.DTMFConfirm {
+		return ""
+	}
+	if key := strings.TrimSpace(s.config.EC600N.DTMFKey); key != "" {
+		return key
+	}
+	return DefaultDTMFKey
+}
+
+// sendAckNotification 发送告警确认通知
+func (s *HTTPServer) sendAckNotification(name string, ack *job.Ack) {
+	if s.notify == nil {
+		return
+	}
+
+	message := &notification.Message{
+		Event: notification.EventAck,
+		Title: "✅ 告警已确认",
+		Fields: []notification.Field{
+			{Name: "名称", Value: name},
+			{Name: "确认人", Value: ack.Number},
+			{Name: "方式", Value: ackMethodText[ack.Method]},
+			{Name: "时间", Value: ack.At.Format("2006-01-02 15:04:05")},
+		},
+		Note: "已停止后续拨号",
+	}
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送确认通知失败: %v", err)
+	}
+}
+
+// isAckMessage 判断短信内容是否为确认关键字（忽略大小写与首尾空白）
+func (s *HTTPServer) isAckMessage(text string) bool {
+	keywords := s.config.Ack.Keywords
+	if len(keywords) == 0 {
+		keywords = DefaultAckKeywords
+	}
+
+	text = strings.TrimSpace(text)
+	for _, k := range keywords {
+		if strings.EqualFold(text, strings.TrimSpace(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// samePhoneNumber 判断两个号码是否相同，忽略分隔符与 +86/86 国家码前缀
+func samePhoneNumber(a, b string) bool {
+	na, nb := contact.CanonicalPhone(a), contact.CanonicalPhone(b)
+	return na != "" && na == nb
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultZabbixNameField Zabbix 告警标题默认字段
+	DefaultZabbixNameField = "subject"
+	// DefaultZabbixStatusField Zabbix 告警状态默认字段
+	DefaultZabbixStatusField = "status"
+	// DefaultZabbixSeverityField Zabbix 告警级别默认字段
+	DefaultZabbixSeverityField = "severity"
+)
+
+// DefaultZabbixResolvedValues Zabbix 状态字段表示恢复的默认取值
+var DefaultZabbixResolvedValues = []string{"RESOLVED", "OK", "0"}
+
+// GrafanaAlert Grafana 统一告警 webhook 中的单条告警，在 Alertmanager 格式基础上附加面板信息
+type GrafanaAlert struct {
+	AlertmanagerAlert
+	DashboardURL string `json:"dashboardURL"`
+	PanelURL     string `json:"panelURL"`
+	SilenceURL   string `json:"silenceURL"`
+	ValueString  string `json:"valueString"`
+}
+
+// GrafanaMessage Grafana 统一告警 webhook 请求结构
+type GrafanaMessage struct {
+	Receiver          string            `json:"receiver"`
+	Status            string            `json:"status"`
+	OrgID             int64             `json:"orgId"`
+	Alerts            []GrafanaAlert    `json:"alerts"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Title             string            `json:"title"`
+	State             string            `json:"state"`
+	Message           string            `json:"message"`
+}
+
+// parseGrafana 解析 Grafana webhook 请求体，告警名称取规则标题（alertname 标签）
+func parseGrafana(body *json.Decoder) ([]*webhookAlert, error) {
+	var msg GrafanaMessage
+	if err := body.Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]*webhookAlert, 0, len(msg.Alerts))
+	for i := range msg.Alerts {
+		alerts = append(alerts, msg.Alerts[i].toWebhookAlert())
+	}
+	return alerts, nil
+}
+
+// parseZabbix 解析 Zabbix 媒介类型 webhook 请求体
+// 请求体的顶层字段作为路由标签，字段映射指定标题、状态与级别所在的字段；
+// 值为 [{"tag":"..","value":".."}] 形式的字段（如 {EVENT.TAGSJSON}）展开为标签
+func (s *HTTPServer) parseZabbix(body *json.Decoder) ([]*webhookAlert, error) {
+	var payload map[string]any
+	if err := body.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	cfg := s.config.Zabbix
+	fields := map[string]string{
+		DefaultZabbixNameField:     cfg.Fields.Name,
+		DefaultZabbixStatusField:   cfg.Fields.Status,
+		DefaultZabbixSeverityField: cfg.Fields.Severity,
+	}
+	for label, field := range fields {
+		if field == "" {
+			fields[label] = label
+		}
+	}
+
+	labels := make(map[string]string, len(payload))
+	for k, v := range payload {
+		switch v := v.(type) {
+		case string:
+			labels[k] = v
+		case float64, bool:
+			labels[k] = fmt.Sprint(v)
+		case []any:
+			for _, tag := range v {
+				if m, ok := tag.(map[string]any); ok {
+					if name, ok := m["tag"].(string); ok {
+						labels[name] = fmt.Sprint(m["value"])
+					}
+				}
+			}
+		}
+	}
+	// 映射字段统一以 status、severity 作为标签名，路由无需关心 Zabbix 中的字段名
+	labels[DefaultZabbixStatusField] = labels[fields[DefaultZabbixStatusField]]
+	labels[DefaultZabbixSeverityField] = labels[fields[DefaultZabbixSeverityField]]
+
+	name := strings.TrimSpace(labels[fields[DefaultZabbixNameField]])
+	if name == "" {
+		return nil, fmt.Errorf("告警标题字段为空: %s", fields[DefaultZabbixNameField])
+	}
+
+	resolvedValues := cfg.ResolvedValues
+	if len(resolvedValues) == 0 {
+		resolvedValues = DefaultZabbixResolvedValues
+	}
+	resolved := false
+	for _, v := range resolvedValues {
+		if strings.EqualFold(strings.TrimSpace(labels[DefaultZabbixStatusField]), v) {
+			resolved = true
+			break
+		}
+	}
+
+	return []*webhookAlert{{Name: name, Labels: labels, Resolved: resolved}}, nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AlertmanagerAlert Alertmanager webhook 中的单条告警
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerMessage Alertmanager webhook（version 4）请求结构
+type AlertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+// alertName 返回告警名称，有 summary 注解时附加在名称之后
+func alertName(alert *AlertmanagerAlert) string {
+	name := alert.Labels["alertname"]
+	if name == "" {
+		name = "未命名告警"
+	}
+	if summary := strings.TrimSpace(alert.Annotations["summary"]); summary != "" {
+		name = fmt.Sprintf("%s: %s", name, summary)
+	}
+	return name
+}
+
+// alertDedupKey 返回告警的去重键：使用 Alertmanager 按标签计算的 fingerprint，
+// 未携带时以告警名称与全部标签标识，注解变化不影响去重
+func alertDedupKey(alert *AlertmanagerAlert) string {
+	if fingerprint := strings.TrimSpace(alert.Fingerprint); fingerprint != "" {
+		return fingerprint
+	}
+	return fmt.Sprintf("%s{%s}", alert.Labels["alertname"], formatLabels(alert.Labels))
+}
+
+// toWebhookAlert 转换为通用告警
+func (a *AlertmanagerAlert) toWebhookAlert() *webhookAlert {
+	return &webhookAlert{
+		Name:     alertName(a),
+		Labels:   a.Labels,
+		Resolved: a.Status == AlertStatusResolved,
+		StartsAt: a.StartsAt,
+		EndsAt:   a.EndsAt,
+		DedupKey: alertDedupKey(a),
+	}
+}
+
+// parseAlertmanager 解析 Alertmanager webhook 请求体
+func parseAlertmanager(body *json.Decoder) ([]*webhookAlert, error) {
+	var msg AlertmanagerMessage
+	if err := body.Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]*webhookAlert, 0, len(msg.Alerts))
+	for i := range msg.Alerts {
+		alerts = append(alerts, msg.Alerts[i].toWebhookAlert())
+	}
+	return alerts, nil
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/contact"
+)
+
+const (
+	// ScopeCall 提交拨打电话的通知
+	ScopeCall = "call"
+	// ScopeSMS 提交告警短信通知、查询收到的短信
+	ScopeSMS = "sms"
+	// ScopeStatus 查询任务状态与值班信息
+	ScopeStatus = "status"
+	// ScopeAdmin 全部权限，可取消任意任务
+	ScopeAdmin = "admin"
+
+	// DefaultClientID 使用 api.secret_key 签名的请求对应的客户端
+	DefaultClientID = "default"
+	// LegacyClientID 旧版 MD5 签名的请求对应的客户端，只能提交通知与查询，不能取消其他客户端的任务
+	LegacyClientID = "legacy"
+	// HeaderClientID v2 签名的客户端 ID 请求头，未携带时使用 api.secret_key
+	HeaderClientID = "X-Client-ID"
+)
+
+var (
+	// errForbidden 客户端无权执行该操作
+	errForbidden = errors.New("权限不足")
+	// errRateLimited 请求超过限流
+	errRateLimited = errors.New("请求过于频繁")
+)
+
+// placeholderSecrets 示例配置中的占位密钥，配置为这些值时拒绝启动
+var placeholderSecrets = []string{"change-me", "changeme", "change_me", "your-secret", "xxxx"}
+
+// apiClient 调用方客户端
+type apiClient struct {
+	id        string
+	secrets   []string        // 有效密钥，密钥轮换期间新旧密钥同时有效
+	scopes    map[string]bool // 允许的权限
+	numbers   map[string]bool // 允许通知的号码（规范化），为空且 teams 为空时不限制
+	teams     map[string]bool // 允许通知的团队
+	rateLimit int             // 每分钟最多请求数，0 不限制
+}
+
+// newClients 根据配置创建客户端，api.secret_key 对应拥有全部权限的 default 客户端
+func newClients(cfg *config.Config) map[string]*apiClient {
+	clients := make(map[string]*apiClient, len(cfg.API.Clients)+1)
+	clients[DefaultClientID] = &apiClient{id: DefaultClientID, scopes: map[string]bool{ScopeAdmin: true}}
+	if cfg.API.SecretKey != "" {
+		clients[DefaultClientID].secrets = []string{cfg.API.SecretKey}
+	}
+
+	for _, c := range cfg.API.Clients {
+		client := &apiClient{
+			id:        c.ID,
+			scopes:    make(map[string]bool, len(c.Scopes)),
+			numbers:   make(map[string]bool, len(c.AllowedNumbers)),
+			teams:     make(map[string]bool, len(c.AllowedTeams)),
+			rateLimit: c.RateLimit,
+		}
+		for _, secret := range c.Secrets {
+			if secret != "" {
+				client.secrets = append(client.secrets, secret)
+			}
+		}
+		for _, scope := range c.Scopes {
+			client.scopes[strings.TrimSpace(scope)] = true
+		}
+		for _, number := range c.AllowedNumbers {
+			client.numbers[contact.CanonicalPhone(number)] = true
+		}
+		for _, team := range c.AllowedTeams {
+			client.teams[strings.TrimSpace(team)] = true
+		}
+		clients[c.ID] = client
+	}
+	return clients
+}
+
+// newLegacyClient 创建旧版 MD5 签名请求使用的客户端
+// 旧版签名可在时间戳容差内重放，不授予 admin 权限
+func newLegacyClient() *apiClient {
+	return &apiClient{id: LegacyClientID, scopes: map[string]bool{ScopeCall: true, ScopeSMS: true, ScopeStatus: true}}
+}
+
+// validateSecrets 检查 api.secret_key 与客户端密钥，仍为占位值时返回错误
+func validateSecrets(cfg *config.Config) error {
+	if isPlaceholderSecret(cfg.API.SecretKey) {
+		return fmt.Errorf("api.secret_key 仍为示例占位值，请修改后再启动")
+	}
+	for _, c := range cfg.API.Clients {
+		for _, secret := range c.Secrets {
+			if isPlaceholderSecret(secret) {
+				return fmt.Errorf("客户端 %s 的密钥仍为示例占位值，请修改后再启动", c.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// isPlaceholderSecret 密钥是否为示例占位值
+func isPlaceholderSecret(secret string) bool {
+	secret = strings.ToLower(strings.TrimSpace(secret))
+	for _, placeholder := range placeholderSecrets {
+		if secret == placeholder {
+			return true
+		}
+	}
+	return false
+}
+
+// allow 客户端是否拥有 scope 权限
+func (c *apiClient) allow(scope string) bool {
+	return c.scopes[ScopeAdmin] || c.scopes[scope]
+}
+
+// requireScopes 检查客户端是否拥有全部权限
+func (c *apiClient) requireScopes(scopes ...string) error {
+	for _, scope := range scopes {
+		if !c.allow(scope) {
+			return fmt.Errorf("%w: 客户端 %s 缺少 %s 权限", errForbidden, c.id, scope)
+		}
+	}
+	return nil
+}
+
+// restricted 客户端是否限制了通知对象
+func (c *apiClient) restricted() bool {
+	return len(c.numbers) > 0 || len(c.teams) > 0
+}
+
+// checkTargets 检查请求的通知对象是否在客户端允许范围内
+// 受限客户端只能通知允许的号码、允许的团队及其成员，不能使用升级策略与值班表
+func (c *apiClient) checkTargets(req *NotifyRequest, directory *contact.Directory) error {
+	if !c.restricted() {
+		return nil
+	}
+	if req.Escalation != "" || req.Schedule != "" {
+		return fmt.Errorf("%w: 客户端 %s 不能使用升级策略或值班表", errForbidden, c.id)
+	}
+	for _, number := range parsePhoneNumbers(req.PhoneNumbers) {
+		if !c.numbers[contact.CanonicalPhone(number)] {
+			return fmt.Errorf("%w: 客户端 %s 不能通知号码 %s", errForbidden, c.id, number)
+		}
+	}
+	for _, ref := range req.Contacts {
+		ref = strings.TrimSpace(ref)
+		if team, ok := strings.CutPrefix(ref, contact.TeamPrefix); ok {
+			if !c.teams[team] {
+				return fmt.Errorf("%w: 客户端 %s 不能通知团队 %s", errForbidden, c.id, team)
+			}
+			continue
+		}
+		allowed := false
+		for team := range c.teams {
+			if directory.IsMember(team, ref) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: 客户端 %s 不能通知联系人 %s", errForbidden, c.id, ref)
+		}
+	}
+	return nil
+}
+
+// modeScopes 返回通知方式所需的权限
+func modeScopes(mode string) []string {
+	switch mode {
+	case NotifyModeSMS:
+		return []string{ScopeSMS}
+	case NotifyModeSMSCall:
+		return []string{ScopeCall, ScopeSMS}
+	default:
+		return []string{ScopeCall}
+	}
+}
+
+// admitClient 检查客户端权限与请求频率
+func (s *HTTPServer) admitClient(client *apiClient, scopes ...string) error {
+	if err := client.requireScopes(scopes...); err != nil {
+		return err
+	}
+	if client.rateLimit > 0 && !s.requests.allow(client.id, client.rateLimit, time.Minute, time.Now()) {
+		return fmt.Errorf("%w: 客户端 %s 每分钟最多 %d 次请求", errRateLimited, client.id, client.rateLimit)
+	}
+	return nil
+}
+
+// authStatus 返回认证或授权错误对应的 HTTP 状态码
+func authStatus(err error) int {
+	switch {
+	case errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusUnauthorized
+	}
+}
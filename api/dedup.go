@@ -0,0 +1,44 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"alert-mobile-notify/contact"
+)
+
+// dedupKey 返回请求的去重键：显式指定 dedupKey 时使用该值，开启去重窗口时使用告警名称，否则不去重
+func (s *HTTPServer) dedupKey(req *NotifyRequest) string {
+	if key := strings.TrimSpace(req.DedupKey); key != "" {
+		return key
+	}
+	if s.config.Dedup.Window > 0 {
+		return req.Name
+	}
+	return ""
+}
+
+// notifyGroupKey 返回请求的分组键，通知方式与通知对象相同的告警属于同一分组
+func notifyGroupKey(req *NotifyRequest) string {
+	numbers := parsePhoneNumbers(req.PhoneNumbers)
+	for i, n := range numbers {
+		numbers[i] = contact.CanonicalPhone(n)
+	}
+	sort.Strings(numbers)
+
+	contacts := make([]string, 0, len(req.Contacts))
+	for _, c := range req.Contacts {
+		if c = strings.TrimSpace(c); c != "" {
+			contacts = append(contacts, c)
+		}
+	}
+	sort.Strings(contacts)
+
+	return strings.Join([]string{
+		req.Mode,
+		req.Escalation,
+		req.Schedule,
+		strings.Join(numbers, ","),
+		strings.Join(contacts, ","),
+	}, "|")
+}
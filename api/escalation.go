@@ -0,0 +1,242 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-mobile-notify/contact"
+	"alert-mobile-notify/job"
+	"alert-mobile-notify/notification"
+
+	"go.uber.org/zap"
+)
+
+// resolveEscalation 根据请求生成升级层级与执行轮数
+// 指定 escalation 时使用配置中的升级策略，否则 phoneNumbers、contacts 与 schedule 的值班人员作为单一层级依次拨打一轮
+func (s *HTTPServer) resolveEscalation(req *NotifyRequest) ([]job.Tier, int, error) {
+	if req.Escalation == "" {
+		tier := job.Tier{Numbers: parsePhoneNumbers(req.PhoneNumbers), Schedule: req.Schedule, Contacts: req.Contacts}
+		if tier.Schedule != "" && s.schedules[tier.Schedule] == nil {
+			return nil, 0, fmt.Errorf("值班表不存在: %s", tier.Schedule)
+		}
+		if err := s.contacts.Validate(tier.Contacts); err != nil {
+			return nil, 0, err
+		}
+		if len(tier.Numbers) == 0 && tier.Schedule == "" && len(tier.Contacts) == 0 {
+			return nil, 0, fmt.Errorf("电话号码为空")
+		}
+		return []job.Tier{tier}, 1, nil
+	}
+
+	policy, ok := s.config.Escalation.Policies[req.Escalation]
+	if !ok {
+		return nil, 0, fmt.Errorf("升级策略不存在: %s", req.Escalation)
+	}
+
+	var tiers []job.Tier
+	for _, t := range policy.Tiers {
+		numbers := parsePhoneNumbers(strings.Join(t.Numbers, ","))
+		if t.Schedule != "" && s.schedules[t.Schedule] == nil {
+			return nil, 0, fmt.Errorf("升级策略 %s 引用的值班表不存在: %s", req.Escalation, t.Schedule)
+		}
+		if err := s.contacts.Validate(t.Contacts); err != nil {
+			return nil, 0, fmt.Errorf("升级策略 %s: %w", req.Escalation, err)
+		}
+		if len(numbers) == 0 && t.Schedule == "" && len(t.Contacts) == 0 {
+			continue
+		}
+		tiers = append(tiers, job.Tier{Numbers: numbers, Schedule: t.Schedule, Contacts: t.Contacts, Wait: t.Wait})
+	}
+	if len(tiers) == 0 {
+		return nil, 0, fmt.Errorf("升级策略未配置号码: %s", req.Escalation)
+	}
+
+	repeat := policy.Repeat
+	if repeat <= 0 {
+		repeat = 1
+	}
+	return tiers, repeat, nil
+}
+
+// tierNumbers 返回所有层级中的号码（去重，保持顺序）
+func tierNumbers(tiers []job.Tier) []string {
+	seen := make(map[string]bool)
+	var numbers []string
+	for _, t := range tiers {
+		for _, n := range t.Numbers {
+			key := contact.CanonicalPhone(n)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// resolveTargets 将层级中的联系人与值班表解析为 at 时刻应通知的号码，依次追加到该层级号码之后
+func (s *HTTPServer) resolveTargets(tiers []job.Tier, at time.Time) []job.Tier {
+	resolved := make([]job.Tier, 0, len(tiers))
+	for _, t := range tiers {
+		numbers := append([]string(nil), t.Numbers...)
+		recipients, err := s.contacts.Resolve(t.Contacts, at)
+		if err != nil {
+			zap.S().Warnf("解析联系人失败，跳过: %v", err)
+		}
+		for _, r := range recipients {
+			numbers = append(numbers, r.Phone)
+		}
+		if schedule := s.schedules[t.Schedule]; schedule != nil {
+			shift := schedule.At(at)
+			zap.S().Infof("值班表 %s 当前值班: %s (%s)", t.Schedule, shift.Name, shift.Phone)
+			numbers = append(numbers, shift.Phone)
+		} else if t.Schedule != "" {
+			zap.S().Warnf("值班表不存在，跳过: %s", t.Schedule)
+		}
+		resolved = append(resolved, job.Tier{Numbers: tierNumbers([]job.Tier{{Numbers: numbers}}), Wait: t.Wait})
+	}
+	return resolved
+}
+
+// describeNumbers 返回带联系人姓名的号码列表描述，以及需要在企业微信中 @ 提醒的用户与手机号
+// 配置了企业微信用户 ID 的联系人按用户 ID 提醒，其他号码按手机号提醒
+func (s *HTTPServer) describeNumbers(numbers []string) (string, []string, []string) {
+	parts := make([]string, 0, len(numbers))
+	var mentions, mobiles []string
+	mentioned := make(map[string]bool)
+	for _, n := range numbers {
+		p := s.contacts.LookupPhone(n)
+		if p == nil || p.WechatUserID == "" {
+			if !mentioned[n] {
+				mentioned[n] = true
+				mobiles = append(mobiles, n)
+			}
+		}
+		if p == nil {
+			parts = append(parts, n)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", p.Name, n))
+		if p.WechatUserID != "" && !mentioned[p.WechatUserID] {
+			mentioned[p.WechatUserID] = true
+			mentions = append(mentions, p.WechatUserID)
+		}
+	}
+	return strings.Join(parts, ", "), mentions, mobiles
+}
+
+// jobTiers 返回任务的升级层级，兼容未记录层级的任务
+func jobTiers(j *job.Job) ([]job.Tier, int) {
+	tiers, repeat := j.Tiers, j.Repeat
+	if len(tiers) == 0 {
+		tiers = []job.Tier{{Numbers: j.PhoneNumbers}}
+	}
+	if repeat <= 0 {
+		repeat = 1
+	}
+	return tiers, repeat
+}
+
+// runEscalation 按升级层级执行通知
+// 依次通知每一级的号码（tiers 中的值班表已解析为号码），未确认时等待该级配置的时间后通知下一级，
+// 整条链路执行 repeat 轮；告警确认或任务取消时立即停止
+func (s *HTTPServer) runEscalation(run *callRun, j *job.Job, tiers []job.Tier, repeat, callDuration int) error {
+	for round := 1; round <= repeat; round++ {
+		for i, tier := range tiers {
+			if stop, err := s.shouldStop(run); stop {
+				return err
+			}
+			if round > 1 || i > 0 {
+				zap.S().Infof("告警未确认，升级通知: id=%s, 第 %d 轮第 %d 级", j.ID, round, i+1)
+				s.sendEscalationNotification(j, round, i+1, tier)
+			}
+
+			// sms_call 只在第一轮发送短信，sms 每轮都发送
+			if j.Mode == NotifyModeSMS || (j.Mode == NotifyModeSMSCall && round == 1) {
+				s.sendAlertSMS(j.Name, tier.Numbers)
+			}
+			if j.Mode != NotifyModeSMS {
+				for _, phoneNumber := range tier.Numbers {
+					if stop, err := s.shouldStop(run); stop {
+						return err
+					}
+					if err := s.limiter.allowCall(phoneNumber, time.Now()); err != nil {
+						zap.S().Warnf("跳过拨号: %v", err)
+						s.sendLimitNotification(j.Name, phoneNumber, err)
+						continue
+					}
+					zap.S().Infof("开始执行拨打电话任务，当前号码: %s，本级号码数: %d", phoneNumber, len(tier.Numbers))
+					result := s.makePhoneCall(run, phoneNumber, callDuration)
+					if err := s.queue.AddCall(j.ID, result); err != nil {
+						zap.S().Errorf("记录呼叫结果失败: %v", err)
+					}
+				}
+			}
+
+			last := round == repeat && i == len(tiers)-1
+			if !last && tier.Wait > 0 {
+				s.waitForAck(run, time.Duration(tier.Wait)*time.Minute)
+			}
+		}
+	}
+
+	_, err := s.shouldStop(run)
+	return err
+}
+
+// shouldStop 判断是否停止后续通知：已确认时返回 nil，已取消时返回 job.ErrCancelled，服务停止时返回 ctx 错误
+func (s *HTTPServer) shouldStop(run *callRun) (bool, error) {
+	if ack := run.acked(); ack != nil {
+		zap.S().Infof("告警已由 %s 确认，停止后续通知", ack.Number)
+		return true, nil
+	}
+	// 取消请求可能在任务登记为当前任务之前到达，此时只记录在队列中
+	if run.aborted() || s.queue.CancelRequested(run.jobID) {
+		return true, job.ErrCancelled
+	}
+	if err := run.ctx.Err(); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// waitForAck 等待告警确认，确认、取消或超时后返回
+func (s *HTTPServer) waitForAck(run *callRun, wait time.Duration) {
+	zap.S().Infof("等待告警确认: %s", wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-run.ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// sendEscalationNotification 发送升级通知
+func (s *HTTPServer) sendEscalationNotification(j *job.Job, round, tier int, t job.Tier) {
+	if s.notify == nil {
+		return
+	}
+
+	numbers, mentions, mobiles := s.describeNumbers(t.Numbers)
+	message := &notification.Message{
+		Event:    notification.EventEscalation,
+		Severity: j.Severity,
+		URL:      s.jobURL(j.ID),
+		Title:    "⏫ 告警未确认，升级通知",
+		Fields: []notification.Field{
+			{Name: "名称", Value: j.Name},
+			{Name: "任务ID", Value: j.ID},
+			{Name: "进度", Value: fmt.Sprintf("第 %d 轮第 %d 级", round, tier)},
+			{Name: "电话号码", Value: numbers},
+		},
+		Mentions:       mentions,
+		MentionMobiles: mobiles,
+	}
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送升级通知失败: %v", err)
+	}
+}
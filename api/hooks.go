@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/jsonpath"
+)
+
+// DefaultHookResolvedValues 通用 webhook 状态表示恢复的默认取值
+var DefaultHookResolvedValues = []string{"resolved", "ok"}
+
+// evalExpr 对请求体求值表达式，返回所有结果值
+// 以 $ 开头时按 JSONPath 取值（数组结果展开），包含 {{ 时按 Go 模板渲染，否则返回固定值
+func evalExpr(body any, expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "":
+		return nil, nil
+	case strings.HasPrefix(expr, "$"):
+		values, err := jsonpath.Find(body, expr)
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		for _, v := range values {
+			out = appendScalar(out, v)
+		}
+		return out, nil
+	case strings.Contains(expr, "{{"):
+		tmpl, err := template.New("hook").Option("missingkey=zero").Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("模板格式错误 [%s]: %w", expr, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, body); err != nil {
+			return nil, fmt.Errorf("模板渲染失败 [%s]: %w", expr, err)
+		}
+		return []string{buf.String()}, nil
+	default:
+		return []string{expr}, nil
+	}
+}
+
+// appendScalar 将 JSON 值转换为字符串追加到 out，数组逐个展开，对象与 null 忽略
+func appendScalar(out []string, v any) []string {
+	switch v := v.(type) {
+	case string:
+		return append(out, v)
+	case float64:
+		return append(out, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		return append(out, strconv.FormatBool(v))
+	case []any:
+		for _, item := range v {
+			out = appendScalar(out, item)
+		}
+	}
+	return out
+}
+
+// evalString 求值表达式并以逗号连接多个结果
+func evalString(body any, expr string) (string, error) {
+	values, err := evalExpr(body, expr)
+	return strings.TrimSpace(strings.Join(values, ", ")), err
+}
+
+// evalList 求值表达式，结果按逗号拆分为去除空白后的列表
+func evalList(body any, expr string) ([]string, error) {
+	values, err := evalExpr(body, expr)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, v := range values {
+		out = append(out, parsePhoneNumbers(v)...)
+	}
+	return out, nil
+}
+
+// parseHook 按 webhook 配置从请求体中提取告警
+// 表达式取到联系人或号码时直接通知，否则以 severity 与 labels 匹配路由
+func parseHook(hook *config.Hook, dec *json.Decoder) ([]*webhookAlert, error) {
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+
+	name, err := evalString(body, hook.Name)
+	if err != nil {
+		return nil, fmt.Errorf("name: %w", err)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("告警名称为空")
+	}
+
+	alert := &webhookAlert{Name: name, Labels: make(map[string]string, len(hook.Labels)+1)}
+	for label, expr := range hook.Labels {
+		if alert.Labels[label], err = evalString(body, expr); err != nil {
+			return nil, fmt.Errorf("labels.%s: %w", label, err)
+		}
+	}
+	if hook.Severity != "" {
+		if alert.Labels["severity"], err = evalString(body, hook.Severity); err != nil {
+			return nil, fmt.Errorf("severity: %w", err)
+		}
+	}
+	if alert.DedupKey, err = evalString(body, hook.DedupKey); err != nil {
+		return nil, fmt.Errorf("dedup_key: %w", err)
+	}
+
+	status, err := evalString(body, hook.Status)
+	if err != nil {
+		return nil, fmt.Errorf("status: %w", err)
+	}
+	resolvedValues := hook.ResolvedValues
+	if len(resolvedValues) == 0 {
+		resolvedValues = DefaultHookResolvedValues
+	}
+	for _, v := range resolvedValues {
+		if status != "" && strings.EqualFold(status, v) {
+			alert.Resolved = true
+			break
+		}
+	}
+
+	contacts, err := evalList(body, hook.Contacts)
+	if err != nil {
+		return nil, fmt.Errorf("contacts: %w", err)
+	}
+	numbers, err := evalList(body, hook.PhoneNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("phone_numbers: %w", err)
+	}
+	if len(contacts) > 0 || len(numbers) > 0 {
+		alert.Route = &config.AlertRoute{
+			Contacts:     contacts,
+			PhoneNumbers: numbers,
+			Mode:         hook.Mode,
+			Priority:     hook.Priority,
+		}
+	}
+
+	return []*webhookAlert{alert}, nil
+}
+
+// handleHook 处理 /api/hooks/{name} 请求，按配置的表达式从任意 JSON 请求体中提取告警
+func (s *HTTPServer) handleHook(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/hooks/")
+	hook, ok := s.config.Hooks[name]
+	if !ok {
+		s.writeErrorResponse(w, http.StatusNotFound, "webhook 不存在")
+		return
+	}
+
+	s.handleWebhook(w, r, "webhook "+name, &hook.WebhookReceiver, func(dec *json.Decoder) ([]*webhookAlert, error) {
+		return parseHook(&hook, dec)
+	})
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/contact"
+	"alert-mobile-notify/notification"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// globalCallKey 全局拨号计数的键
+	globalCallKey = "global"
+)
+
+// slidingWindow 按键统计滑动窗口内的事件数
+type slidingWindow struct {
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// newSlidingWindow 创建滑动窗口计数器
+func newSlidingWindow() *slidingWindow {
+	return &slidingWindow{events: make(map[string][]time.Time)}
+}
+
+// allow 窗口内事件数小于 limit 时记录本次事件并返回 true
+func (w *slidingWindow) allow(key string, limit int, window time.Duration, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kept := w.prune(key, window, now)
+	if len(kept) >= limit {
+		return false
+	}
+	w.events[key] = append(kept, now)
+	return true
+}
+
+// full 窗口内事件数是否已达到 limit，不记录事件
+func (w *slidingWindow) full(key string, limit int, window time.Duration, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.prune(key, window, now)) >= limit
+}
+
+// prune 移除窗口外的事件并返回窗口内的事件，调用方需持有锁
+func (w *slidingWindow) prune(key string, window time.Duration, now time.Time) []time.Time {
+	events := w.events[key]
+	kept := events[:0]
+	for _, at := range events {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(w.events, key)
+		return nil
+	}
+	w.events[key] = kept
+	return kept
+}
+
+// callLimiter 拨号限流：每个号码与全局每小时拨打次数，以及同名告警的冷却时间
+type callLimiter struct {
+	numberLimit int
+	globalLimit int
+	cooldown    time.Duration
+
+	calls *slidingWindow
+
+	mu     sync.Mutex
+	alerts map[string]time.Time // 告警名称最近一次提交的时间
+}
+
+// newCallLimiter 根据配置创建拨号限流
+func newCallLimiter(cfg *config.Config) *callLimiter {
+	return &callLimiter{
+		numberLimit: cfg.Limits.NumberCallsPerHour,
+		globalLimit: cfg.Limits.CallsPerHour,
+		cooldown:    time.Duration(cfg.Limits.AlertCooldown) * time.Minute,
+		calls:       newSlidingWindow(),
+		alerts:      make(map[string]time.Time),
+	}
+}
+
+// admit 检查是否允许提交告警，允许时记录告警提交时间
+// calls 为 false（仅发送短信）时只检查冷却时间；号码全部超出每小时拨打次数或全局超限时拒绝
+func (l *callLimiter) admit(name string, numbers []string, calls bool, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.alerts[name]; ok && l.cooldown > 0 && now.Sub(last) < l.cooldown {
+		return fmt.Errorf("%w: 告警 %s 处于冷却期，%s 后可再次通知", errRateLimited, name, (l.cooldown - now.Sub(last)).Round(time.Second))
+	}
+
+	if calls {
+		if l.globalLimit > 0 && l.calls.full(globalCallKey, l.globalLimit, time.Hour, now) {
+			return fmt.Errorf("%w: 全局每小时最多拨打 %d 次", errRateLimited, l.globalLimit)
+		}
+		if l.numberLimit > 0 && len(numbers) > 0 {
+			exhausted := 0
+			for _, n := range numbers {
+				if l.calls.full(contact.CanonicalPhone(n), l.numberLimit, time.Hour, now) {
+					exhausted++
+				}
+			}
+			if exhausted == len(numbers) {
+				return fmt.Errorf("%w: 号码均已达到每小时 %d 次拨打上限", errRateLimited, l.numberLimit)
+			}
+		}
+	}
+
+	l.touch(name, now)
+	return nil
+}
+
+// record 记录告警提交时间，不检查限流，用于合并到已有任务的告警
+func (l *callLimiter) record(name string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.touch(name, now)
+}
+
+// touch 清理冷却期已过的告警并记录告警提交时间，调用方需持有锁
+func (l *callLimiter) touch(name string, now time.Time) {
+	for n, at := range l.alerts {
+		if now.Sub(at) >= l.cooldown {
+			delete(l.alerts, n)
+		}
+	}
+	l.alerts[name] = now
+}
+
+// allowCall 拨号前检查并记录一次拨打，超出号码或全局每小时拨打次数时返回错误
+func (l *callLimiter) allowCall(number string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := contact.CanonicalPhone(number)
+	if l.numberLimit > 0 && l.calls.full(key, l.numberLimit, time.Hour, now) {
+		return fmt.Errorf("%w: 号码 %s 每小时最多拨打 %d 次", errRateLimited, number, l.numberLimit)
+	}
+	if l.globalLimit > 0 && !l.calls.allow(globalCallKey, l.globalLimit, time.Hour, now) {
+		return fmt.Errorf("%w: 全局每小时最多拨打 %d 次", errRateLimited, l.globalLimit)
+	}
+	if l.numberLimit > 0 {
+		l.calls.allow(key, l.numberLimit, time.Hour, now)
+	}
+	return nil
+}
+
+// sendLimitNotification 发送限流通知，告知告警未拨打电话的原因
+func (s *HTTPServer) sendLimitNotification(name, numbers string, reason error) {
+	if s.notify == nil {
+		return
+	}
+
+	message := &notification.Message{
+		Event: notification.EventLimit,
+		Title: "🚫 告警触发拨号限流，未拨打电话",
+		Fields: []notification.Field{
+			{Name: "名称", Value: name},
+			{Name: "电话号码", Value: numbers},
+			{Name: "原因", Value: reason.Error()},
+			{Name: "时间", Value: time.Now().Format("2006-01-02 15:04:05")},
+		},
+	}
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送限流通知失败: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"alert-mobile-notify/oncall"
+)
+
+// OnCallStatus 值班表当前与下一班次
+type OnCallStatus struct {
+	Schedule string        `json:"schedule"`
+	Current  *oncall.Shift `json:"current"`
+	Next     *oncall.Shift `json:"next"`
+}
+
+// OnCallResponse 值班查询响应结构
+type OnCallResponse struct {
+	Success   bool           `json:"success"`
+	Schedules []OnCallStatus `json:"schedules"`
+}
+
+// handleOnCall 处理 /api/oncall 请求，返回当前与下一班值班人员
+// 可选参数 schedule 指定值班表，默认返回全部值班表
+func (s *HTTPServer) handleOnCall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		s.writeErrorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, err := s.authenticateQuery(r, ScopeStatus); err != nil {
+		s.writeErrorResponse(w, authStatus(err), err.Error())
+		return
+	}
+
+	names := s.schedules.Names()
+	if name := r.URL.Query().Get("schedule"); name != "" {
+		if s.schedules[name] == nil {
+			s.writeErrorResponse(w, http.StatusNotFound, "值班表不存在")
+			return
+		}
+		names = []string{name}
+	}
+
+	now := time.Now()
+	statuses := make([]OnCallStatus, 0, len(names))
+	for _, name := range names {
+		schedule := s.schedules[name]
+		statuses = append(statuses, OnCallStatus{
+			Schedule: name,
+			Current:  schedule.At(now),
+			Next:     schedule.Next(now),
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OnCallResponse{Success: true, Schedules: statuses})
+}
@@ -2,14 +2,20 @@ package api
 
 import (
 	"alert-mobile-notify/config"
+	"alert-mobile-notify/contact"
 	"alert-mobile-notify/ec600n"
+	"alert-mobile-notify/job"
 	"alert-mobile-notify/notification"
+	"alert-mobile-notify/oncall"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,20 +31,58 @@ const (
 	DefaultHTTPPort = 8080
 	// TimestampTolerance 时间戳容差（分钟）
 	TimestampTolerance = 5
+	// DefaultSMSListLimit 短信列表默认返回条数
+	DefaultSMSListLimit = 50
+	// DefaultAnnouncementTemplate 通话中默认播报模板，{name} 替换为告警名称
+	DefaultAnnouncementTemplate = "告警：{name}，请查看企业微信"
 )
 
+const (
+	// NotifyModeCall 仅拨打电话（默认）
+	NotifyModeCall = "call"
+	// NotifyModeSMSCall 先发送告警短信再拨打电话
+	NotifyModeSMSCall = "sms_call"
+	// NotifyModeSMS 仅发送告警短信，不拨打电话
+	NotifyModeSMS = "sms"
+)
+
+// errUnavailable 暂时无法提交通知任务，如 EC600N 未就绪或任务保存失败，调用方可稍后重试
+var errUnavailable = errors.New("服务暂不可用")
+
 // NotifyRequest API请求结构
 type NotifyRequest struct {
-	Name         string `json:"name"`
-	PhoneNumbers string `json:"phoneNumbers"`
-	Timestamp    string `json:"timestamp"`
-	Signature    string `json:"signature"`
+	Name         string   `json:"name"`
+	PhoneNumbers string   `json:"phoneNumbers"`
+	Mode         string   `json:"mode,omitempty"`       // 通知方式：call（默认）、sms_call、sms
+	Priority     int      `json:"priority,omitempty"`   // 任务优先级，数值越大越先执行，默认 0
+	Escalation   string   `json:"escalation,omitempty"` // 升级策略名称，未指定时依次拨打 phoneNumbers
+	Schedule     string   `json:"schedule,omitempty"`   // 值班表名称，拨打时解析为当前值班人员号码
+	Contacts     []string `json:"contacts,omitempty"`   // 联系人或团队（team:<ID>），拨打时解析为号码
+	Severity     string   `json:"severity,omitempty"`   // 告警级别（critical、warning、info），用于企业微信消息的颜色与标签
+	DedupKey     string   `json:"dedupKey,omitempty"`   // 去重键，去重窗口内相同去重键的告警只通知一次，默认为告警名称
+	ClientID     string   `json:"-"`                    // 签名验证通过的客户端 ID
+	Timestamp    string   `json:"timestamp,omitempty"`  // 旧版 MD5 签名时间戳，v2 签名通过请求头携带
+	Signature    string   `json:"signature,omitempty"`  // 旧版 MD5 签名
 }
 
 // NotifyResponse API响应结构
 type NotifyResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
+	JobID   string `json:"jobId,omitempty"` // 任务 ID
+}
+
+// JobResponse 任务查询与取消响应结构
+type JobResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message,omitempty"`
+	Job     *job.Job `json:"job,omitempty"`
+}
+
+// SMSListResponse 短信列表响应结构
+type SMSListResponse struct {
+	Success  bool                 `json:"success"`
+	Messages []*ec600n.SMSMessage `json:"messages"`
 }
 
 // HTTPServer HTTP服务器
@@ -46,16 +90,30 @@ type HTTPServer struct {
 	config    *config.Config
 	server    *http.Server
 	secretKey string
+	nonces    *nonceCache           // v2 签名已使用的随机串
+	clients   map[string]*apiClient // 调用方客户端，键为客户端 ID
+	legacy    *apiClient            // 旧版 MD5 签名请求的客户端
+	requests  *slidingWindow        // 客户端请求频率统计
+	limiter   *callLimiter          // 拨号限流
 	ec600n    *ec600n.EC600N
-	notify    *notification.WechatNotify
+	notify    notification.Notifier
+	queue     *job.Queue
+	schedules oncall.Schedules
+	contacts  *contact.Directory
+
+	// 告警去重、合并与提交任务互斥
+	dedupMu sync.Mutex
 
-	// 电话拨打状态控制
+	// 电话拨打状态控制，current 为正在执行的拨号任务
 	callMu  sync.Mutex
-	calling bool
+	current *callRun
+
+	stopAckWatch func()
+	stopWorker   func()
 }
 
 // NewHTTPServer 创建新的HTTP服务器
-func NewHTTPServer(cfg *config.Config, ec600nModule *ec600n.EC600N, notify *notification.WechatNotify) *HTTPServer {
+func NewHTTPServer(cfg *config.Config, ec600nModule *ec600n.EC600N, notify notification.Notifier, queue *job.Queue, schedules oncall.Schedules, contacts *contact.Directory) *HTTPServer {
 	secretKey := cfg.API.SecretKey
 	if secretKey == "" {
 		zap.S().Warn("API secret_key 未配置，签名验证将失败")
@@ -71,8 +129,16 @@ func NewHTTPServer(cfg *config.Config, ec600nModule *ec600n.EC600N, notify *noti
 	server := &HTTPServer{
 		config:    cfg,
 		secretKey: secretKey,
+		nonces:    newNonceCache(2 * TimestampTolerance * time.Minute),
+		clients:   newClients(cfg),
+		legacy:    newLegacyClient(),
+		requests:  newSlidingWindow(),
+		limiter:   newCallLimiter(cfg),
 		ec600n:    ec600nModule,
 		notify:    notify,
+		queue:     queue,
+		schedules: schedules,
+		contacts:  contacts,
 		server: &http.Server{
 			Addr:    fmt.Sprintf(":%d", port),
 			Handler: mux,
@@ -81,6 +147,13 @@ func NewHTTPServer(cfg *config.Config, ec600nModule *ec600n.EC600N, notify *noti
 
 	// 注册路由
 	mux.HandleFunc("/api/nofity", server.handleNotify)
+	mux.HandleFunc("/api/sms", server.handleListSMS)
+	mux.HandleFunc("/api/jobs/", server.handleJob)
+	mux.HandleFunc("/api/oncall", server.handleOnCall)
+	mux.HandleFunc("/api/alertmanager", server.handleAlertmanager)
+	mux.HandleFunc("/api/grafana", server.handleGrafana)
+	mux.HandleFunc("/api/zabbix", server.handleZabbix)
+	mux.HandleFunc("/api/hooks/", server.handleHook)
 
 	return server
 }
@@ -90,17 +163,24 @@ func NewHTTPServer(cfg *config.Config, ec600nModule *ec600n.EC600N, notify *noti
 // 拼接格式：name=value&phoneNumbers=value&secretKey=value&timestamp=value
 // 使用MD5生成签名
 func (s *HTTPServer) generateSignature(name, phoneNumbers, timestamp string) string {
-	// 创建参数映射
-	params := map[string]string{
+	return s.signParams(map[string]string{
 		"name":         name,
 		"phoneNumbers": phoneNumbers,
-		"secretKey":    s.secretKey,
 		"timestamp":    timestamp,
+	})
+}
+
+// signParams 对参数加入 secretKey 后按键名升序拼接为 key=value&key=value，并计算 MD5 签名
+func (s *HTTPServer) signParams(params map[string]string) string {
+	signed := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		signed[k] = v
 	}
+	signed["secretKey"] = s.secretKey
 
 	// 按键名排序
-	keys := make([]string, 0, len(params))
-	for k := range params {
+	keys := make([]string, 0, len(signed))
+	for k := range signed {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -108,7 +188,7 @@ func (s *HTTPServer) generateSignature(name, phoneNumbers, timestamp string) str
 	// 拼接参数字符串
 	var parts []string
 	for _, k := range keys {
-		parts = append(parts, fmt.Sprintf("%s=%s", k, params[k]))
+		parts = append(parts, fmt.Sprintf("%s=%s", k, signed[k]))
 	}
 	signString := strings.Join(parts, "&")
 
@@ -117,10 +197,37 @@ func (s *HTTPServer) generateSignature(name, phoneNumbers, timestamp string) str
 	return hex.EncodeToString(hash[:])
 }
 
-// validateSignature 验证签名
+// validateSignature 验证旧版 MD5 签名
 func (s *HTTPServer) validateSignature(req *NotifyRequest) bool {
 	expectedSignature := s.generateSignature(req.Name, req.PhoneNumbers, req.Timestamp)
-	return strings.EqualFold(expectedSignature, req.Signature)
+	return legacySignatureEqual(expectedSignature, req.Signature)
+}
+
+// unsignedLegacyFields 返回请求中旧版 MD5 签名未覆盖的字段，旧版签名只覆盖 name、phoneNumbers 与 timestamp
+func unsignedLegacyFields(req *NotifyRequest) []string {
+	var fields []string
+	if req.Mode != "" {
+		fields = append(fields, "mode")
+	}
+	if req.Priority != 0 {
+		fields = append(fields, "priority")
+	}
+	if req.Escalation != "" {
+		fields = append(fields, "escalation")
+	}
+	if req.Schedule != "" {
+		fields = append(fields, "schedule")
+	}
+	if len(req.Contacts) > 0 {
+		fields = append(fields, "contacts")
+	}
+	if req.Severity != "" {
+		fields = append(fields, "severity")
+	}
+	if req.DedupKey != "" {
+		fields = append(fields, "dedupKey")
+	}
+	return fields
 }
 
 // validateTimestamp 验证时间戳
@@ -154,6 +261,48 @@ func (s *HTTPServer) validateTimestamp(timestampStr string) (bool, error) {
 	return true, nil
 }
 
+// authenticateQuery 验证查询类接口的签名，并检查客户端是否拥有 scopes 权限
+// 优先验证 v2 签名请求头；开启旧版签名时，也可通过 URL 参数携带 timestamp 与 signature，
+// signature = MD5("secretKey=xxx&timestamp=xxx")
+func (s *HTTPServer) authenticateQuery(r *http.Request, scopes ...string) (*apiClient, error) {
+	client, err := s.authenticateQuerySignature(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.admitClient(client, scopes...); err != nil {
+		zap.S().Warnf("拒绝客户端请求: path=%s, %v", r.URL.Path, err)
+		return nil, err
+	}
+	return client, nil
+}
+
+// authenticateQuerySignature 验证查询类接口的签名，返回请求的客户端
+func (s *HTTPServer) authenticateQuerySignature(r *http.Request) (*apiClient, error) {
+	if client, signed, err := s.verifySignatureV2(r, nil); signed {
+		return client, err
+	}
+	if !s.config.API.LegacySignature {
+		zap.S().Warnf("请求缺少 v2 签名: path=%s，旧版 MD5 签名调用方需设置 api.legacy_signature: true", r.URL.Path)
+		return nil, fmt.Errorf("签名验证失败: 缺少 %s 请求头", HeaderSignature)
+	}
+
+	timestamp := r.URL.Query().Get("timestamp")
+	signature := r.URL.Query().Get("signature")
+
+	expected := s.signParams(map[string]string{"timestamp": timestamp})
+	if !legacySignatureEqual(expected, signature) {
+		zap.S().Warnf("签名验证失败: path=%s, timestamp=%s", r.URL.Path, timestamp)
+		return nil, fmt.Errorf("签名验证失败")
+	}
+
+	if valid, err := s.validateTimestamp(timestamp); !valid {
+		zap.S().Warnf("时间戳验证失败: %v", err)
+		return nil, fmt.Errorf("时间戳验证失败: %w", err)
+	}
+
+	return s.legacy, nil
+}
+
 // writeErrorResponse 写入错误响应
 func (s *HTTPServer) writeErrorResponse(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -167,25 +316,84 @@ func (s *HTTPServer) parseAndValidateRequest(r *http.Request) (*NotifyRequest, e
 		return nil, fmt.Errorf("method not allowed")
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取请求体失败: %w", err)
+	}
+
+	// v2 签名覆盖整个请求体，验证通过后无需再校验请求体中的 timestamp 与 signature
+	client, signed, err := s.verifySignatureV2(r, body)
+	if err != nil {
+		return nil, err
+	}
+
 	var req NotifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		return nil, fmt.Errorf("解析请求体失败: %w", err)
 	}
 
-	if !s.validateSignature(&req) {
-		zap.S().Warnf("签名验证失败: name=%s, phoneNumbers=%s, timestamp=%s",
-			req.Name, req.PhoneNumbers, req.Timestamp)
-		return nil, fmt.Errorf("签名验证失败")
-	}
+	if !signed {
+		if !s.config.API.LegacySignature {
+			zap.S().Warnf("请求缺少 v2 签名: name=%s，旧版 MD5 签名调用方需设置 api.legacy_signature: true", req.Name)
+			return nil, fmt.Errorf("签名验证失败: 缺少 %s 请求头", HeaderSignature)
+		}
+		// 未签名的字段可被重放请求篡改，只能通过 v2 签名携带
+		if fields := unsignedLegacyFields(&req); len(fields) > 0 {
+			zap.S().Warnf("拒绝旧版签名请求: name=%s, 未签名字段: %v", req.Name, fields)
+			return nil, fmt.Errorf("签名验证失败: 旧版签名不覆盖 %s，请使用 v2 签名", strings.Join(fields, ", "))
+		}
+		if !s.validateSignature(&req) {
+			zap.S().Warnf("签名验证失败: name=%s, phoneNumbers=%s, timestamp=%s",
+				req.Name, req.PhoneNumbers, req.Timestamp)
+			return nil, fmt.Errorf("签名验证失败")
+		}
 
-	if valid, err := s.validateTimestamp(req.Timestamp); !valid {
-		zap.S().Warnf("时间戳验证失败: %v", err)
-		return nil, fmt.Errorf("时间戳验证失败: %w", err)
+		if valid, err := s.validateTimestamp(req.Timestamp); !valid {
+			zap.S().Warnf("时间戳验证失败: %v", err)
+			return nil, fmt.Errorf("时间戳验证失败: %w", err)
+		}
+		client = s.legacy
 	}
+	req.ClientID = client.id
 
+	if err := s.validateTargets(&req); err != nil {
+		return nil, err
+	}
+	if err := client.checkTargets(&req, s.contacts); err != nil {
+		zap.S().Warnf("拒绝客户端请求: name=%s, %v", req.Name, err)
+		return nil, err
+	}
+	if err := s.admitClient(client, modeScopes(req.Mode)...); err != nil {
+		zap.S().Warnf("拒绝客户端请求: name=%s, %v", req.Name, err)
+		return nil, err
+	}
 	return &req, nil
 }
 
+// validateTargets 校验通知方式与通知对象，未指定通知方式时默认拨打电话
+func (s *HTTPServer) validateTargets(req *NotifyRequest) error {
+	switch req.Mode {
+	case "":
+		req.Mode = NotifyModeCall
+	case NotifyModeCall, NotifyModeSMSCall, NotifyModeSMS:
+	default:
+		return fmt.Errorf("不支持的通知方式: %s", req.Mode)
+	}
+
+	if req.Escalation != "" {
+		if _, ok := s.config.Escalation.Policies[req.Escalation]; !ok {
+			return fmt.Errorf("升级策略不存在: %s", req.Escalation)
+		}
+		if req.Schedule != "" || len(req.Contacts) > 0 {
+			return fmt.Errorf("escalation 不能与 schedule、contacts 同时指定")
+		}
+	}
+	if req.Schedule != "" && s.schedules[req.Schedule] == nil {
+		return fmt.Errorf("值班表不存在: %s", req.Schedule)
+	}
+	return s.contacts.Validate(req.Contacts)
+}
+
 // parsePhoneNumbers 解析并清理电话号码列表
 func parsePhoneNumbers(phoneNumbersStr string) []string {
 	if phoneNumbersStr == "" {
@@ -202,90 +410,321 @@ func parsePhoneNumbers(phoneNumbersStr string) []string {
 	return cleanNumbers
 }
 
-// sendWechatNotification 发送企业微信通知
-func (s *HTTPServer) sendWechatNotification(name string, phoneNumbers []string) {
-	if s.notify == nil || len(phoneNumbers) == 0 {
+// sendWechatNotification 发送任务通知到配置的通知渠道
+// ahead 为排在该任务前面的任务数，大于 0 时提示任务已排队
+func (s *HTTPServer) sendWechatNotification(j *job.Job, ahead int) {
+	if s.notify == nil || len(j.PhoneNumbers) == 0 {
 		return
 	}
 
-	message := fmt.Sprintf(`📞 名称: %s
-电话号码: %s
-时间: %s
-即将开始拨打电话...`,
-		name,
-		strings.Join(phoneNumbers, ", "),
-		time.Now().Format("2006-01-02 15:04:05"))
+	numbers, mentions, mobiles := s.describeNumbers(j.PhoneNumbers)
+	message := &notification.Message{
+		Event:    notification.EventAlert,
+		Title:    "📞 告警通知: " + j.Title(),
+		Severity: j.Severity,
+		URL:      s.jobURL(j.ID),
+		Fields: []notification.Field{
+			{Name: "名称", Value: j.Name},
+			{Name: "电话号码", Value: numbers},
+			{Name: "时间", Value: time.Now().Format("2006-01-02 15:04:05")},
+			{Name: "任务ID", Value: j.ID},
+		},
+		Mentions:       mentions,
+		MentionMobiles: mobiles,
+	}
 
-	if err := s.notify.SendToWechat(message); err != nil {
-		zap.S().Errorf("发送企业微信通知失败: %v", err)
+	action := "即将开始拨打电话..."
+	switch j.Mode {
+	case NotifyModeSMSCall:
+		action = "即将发送告警短信并拨打电话..."
+	case NotifyModeSMS:
+		action = "即将发送告警短信..."
+	}
+	if ahead > 0 {
+		action = fmt.Sprintf("已加入任务队列，前面还有 %d 个任务", ahead)
+	}
+	if j.Client != "" {
+		message.Fields = append(message.Fields, notification.Field{Name: "来源", Value: j.Client})
+	}
+	if j.Escalation != "" {
+		message.Fields = append(message.Fields, notification.Field{Name: "升级策略", Value: fmt.Sprintf("%s（%d 级，%d 轮）", j.Escalation, len(j.Tiers), j.Repeat)})
+	}
+	if len(j.Alerts) > 1 {
+		names := make([]string, 0, len(j.Alerts))
+		for i, a := range j.Alerts {
+			names = append(names, fmt.Sprintf("%d. %s", i+1, a.Name))
+		}
+		message.Fields[0].Value = fmt.Sprintf("%d 条告警合并通知\n%s", len(j.Alerts), strings.Join(names, "\n"))
 	}
+	message.Note = action
+	// 文本消息以名称开头，标题只用于邮件主题
+	message.Content = "📞 " + notification.FormatFields(message.Fields) + "\n" + action
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送任务通知失败: %v", err)
+	}
+}
+
+// jobURL 返回任务状态页面地址，未配置 api.job_url 时为空
+func (s *HTTPServer) jobURL(id string) string {
+	if s.config.API.JobURL == "" {
+		return ""
+	}
+	return strings.ReplaceAll(s.config.API.JobURL, "{id}", url.PathEscape(id))
 }
 
-// makePhoneCall 拨打电话
-func (s *HTTPServer) makePhoneCall(phoneNumber string, duration int) string {
-	zap.S().Infof("开始拨打电话: %s", phoneNumber)
-	if err := s.ec600n.MakeCall(phoneNumber); err != nil {
+// makePhoneCall 拨打电话并等待呼叫结束，返回呼叫结果
+// 对方挂断、无人接听或拒接时提前结束，接通后最长保持 duration 秒；告警已确认或任务取消时立即挂断。
+// 开启按键确认时，对方在通话中按下确认键视为确认告警
+func (s *HTTPServer) makePhoneCall(run *callRun, phoneNumber string, duration int) *ec600n.CallResult {
+	opts := ec600n.CallOptions{
+		MaxDuration:  time.Duration(duration) * time.Second,
+		RingTimeout:  time.Duration(s.config.EC600N.RingTimeout) * time.Second,
+		ConfirmDigit: s.dtmfConfirmKey(),
+	}
+	if s.config.TTS.Enabled {
+		opts.Announcement = s.buildAnnouncement(run.name)
+		opts.AnnounceFile = s.config.TTS.AudioFile
+		opts.AnnounceRepeat = s.config.TTS.Repeat
+	}
+
+	result, err := s.ec600n.Call(run.ctx, phoneNumber, opts)
+	if err != nil {
 		zap.S().Errorf("拨打电话失败 [%s]: %v", phoneNumber, err)
-		return fmt.Sprintf("%s: 失败 - %v", phoneNumber, err)
 	}
 
-	zap.S().Infof("通话中，等待 %d 秒后挂断...", duration)
-	time.Sleep(time.Duration(duration) * time.Second)
+	if result.Confirmed {
+		if ack, first := run.acknowledge(phoneNumber, AckMethodDTMF); first {
+			s.onAcknowledged(run, ack)
+		}
+	}
+
+	return result
+}
 
-	if err := s.ec600n.HangupCall(); err != nil {
-		zap.S().Errorf("挂断电话失败 [%s]: %v", phoneNumber, err)
-		return fmt.Sprintf("%s: 拨打成功但挂断失败 - %v", phoneNumber, err)
+// buildAnnouncement 根据模板生成通话中的播报内容
+func (s *HTTPServer) buildAnnouncement(name string) string {
+	template := s.config.TTS.Template
+	if template == "" {
+		template = DefaultAnnouncementTemplate
 	}
+	return strings.ReplaceAll(template, "{name}", name)
+}
 
-	zap.S().Infof("电话已挂断: %s", phoneNumber)
-	return fmt.Sprintf("%s: 成功", phoneNumber)
+// buildAlertSMS 构造告警短信内容
+func buildAlertSMS(name string) string {
+	return fmt.Sprintf("【告警通知】%s\n时间: %s\n请及时处理，详情请查看企业微信。",
+		name, time.Now().Format("2006-01-02 15:04:05"))
 }
 
-// processPhoneCalls 处理拨打电话流程
-func (s *HTTPServer) processPhoneCalls(name string, phoneNumbersStr string) error {
-	if phoneNumbersStr == "" || s.ec600n == nil || !s.ec600n.IsConnected() {
-		if s.ec600n == nil || !s.ec600n.IsConnected() {
-			zap.S().Warn("EC600N 模块未启用或未连接，跳过拨打电话")
-			return fmt.Errorf("EC600N 模块未启用或未连接")
+// sendAlertSMS 向所有号码发送告警短信
+func (s *HTTPServer) sendAlertSMS(name string, phoneNumbers []string) {
+	text := buildAlertSMS(name)
+	for _, phoneNumber := range phoneNumbers {
+		if _, err := s.ec600n.SendSMS(phoneNumber, text); err != nil {
+			zap.S().Errorf("发送告警短信失败 [%s]: %v", phoneNumber, err)
 		}
-		return fmt.Errorf("电话号码为空")
 	}
+}
+
+// enqueueResult 提交通知任务的结果
+type enqueueResult struct {
+	job       *job.Job
+	ahead     int  // 排在该任务前面的任务数
+	duplicate bool // 重复告警，返回已有任务
+	merged    bool // 告警已合并到分组窗口内的任务
+}
 
-	phoneNumbers := parsePhoneNumbers(phoneNumbersStr)
-	if len(phoneNumbers) == 0 {
-		return fmt.Errorf("电话号码为空")
+// enqueueNotify 校验请求后提交通知任务
+// 去重窗口内的重复告警返回已有任务；分组窗口内通知对象相同的告警合并到同一任务，
+// 分组窗口结束后才开始执行，企业微信通知在开始执行时发送
+// 通知在释放去重锁之后发送，避免通知渠道阻塞其他告警的提交
+func (s *HTTPServer) enqueueNotify(req *NotifyRequest) (*enqueueResult, error) {
+	tiers, repeat, err := s.resolveEscalation(req)
+	if err != nil {
+		return nil, err
+	}
+	if s.ec600n == nil {
+		zap.S().Warn("EC600N 模块未启用，跳过拨打电话")
+		return nil, fmt.Errorf("%w: EC600N 模块未启用或未连接", errUnavailable)
 	}
 
-	s.sendWechatNotification(name, phoneNumbers)
+	now := time.Now()
+	result, numbers, err := s.admitNotify(req, tiers, repeat, now)
+	if errors.Is(err, errRateLimited) {
+		described, _, _ := s.describeNumbers(numbers)
+		s.sendLimitNotification(req.Name, described, err)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	// 检查是否已有拨号任务在执行
-	s.callMu.Lock()
-	if s.calling {
-		s.callMu.Unlock()
-		return fmt.Errorf("已有任务在处理")
+	if !result.duplicate && !result.merged && result.job.NotBefore.IsZero() {
+		s.sendWechatNotification(result.job, result.ahead)
 	}
-	s.calling = true
-	s.callMu.Unlock()
+	return result, nil
+}
 
-	go func() {
-		defer func() {
-			s.callMu.Lock()
-			s.calling = false
-			s.callMu.Unlock()
-		}()
-
-		callDuration := s.config.EC600N.CallDuration
-		if callDuration <= 0 {
-			callDuration = 10
+// admitNotify 去重、合并或提交通知任务，触发限流时返回被限流的号码
+// 去重、合并与提交需在同一临界区内，避免并发的重复告警同时通过检查
+func (s *HTTPServer) admitNotify(req *NotifyRequest, tiers []job.Tier, repeat int, now time.Time) (*enqueueResult, []string, error) {
+	alert := job.Alert{Name: req.Name, DedupKey: s.dedupKey(req), At: now}
+
+	s.dedupMu.Lock()
+	defer s.dedupMu.Unlock()
+
+	if alert.DedupKey != "" {
+		since := now.Add(-time.Duration(s.config.Dedup.Window) * time.Minute)
+		if dup := s.queue.FindDuplicate(alert.DedupKey, since); dup != nil {
+			zap.S().Infof("忽略重复告警: name=%s, dedupKey=%s, id=%s", req.Name, alert.DedupKey, dup.ID)
+			return &enqueueResult{job: dup, duplicate: true}, nil, nil
 		}
+	}
 
-		for _, phoneNumber := range phoneNumbers {
-			zap.S().Infof("开始执行拨打电话任务，当前号码: %s，总任务数: %d", phoneNumber, len(phoneNumbers))
-			s.makePhoneCall(phoneNumber, callDuration)
+	groupWindow := time.Duration(s.config.Dedup.GroupWindow) * time.Second
+	groupKey := ""
+	if groupWindow > 0 {
+		groupKey = notifyGroupKey(req)
+		merged, err := s.queue.Merge(groupKey, alert, req.Priority, req.Severity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: 合并告警失败: %w", errUnavailable, err)
+		}
+		if merged != nil {
+			// 合并的告警不新增拨号，但仍记录冷却时间，分组窗口结束后同名告警按冷却时间限流
+			s.limiter.record(req.Name, now)
+			zap.S().Infof("告警已合并到任务: name=%s, id=%s, 共 %d 条告警", req.Name, merged.ID, len(merged.Alerts))
+			return &enqueueResult{job: merged, merged: true}, nil, nil
+		}
+	}
+
+	numbers := tierNumbers(s.resolveTargets(tiers, now))
+	if err := s.limiter.admit(req.Name, numbers, req.Mode != NotifyModeSMS, now); err != nil {
+		zap.S().Warnf("告警触发限流: name=%s, %v", req.Name, err)
+		return nil, numbers, err
+	}
+
+	j := &job.Job{
+		Name:         req.Name,
+		PhoneNumbers: numbers,
+		Mode:         req.Mode,
+		Priority:     req.Priority,
+		Escalation:   req.Escalation,
+		Severity:     req.Severity,
+		Alerts:       []job.Alert{alert},
+		GroupKey:     groupKey,
+		Client:       req.ClientID,
+		Tiers:        tiers,
+		Repeat:       repeat,
+	}
+	if groupWindow > 0 {
+		j.NotBefore = now.Add(groupWindow)
+	}
+	ahead, err := s.queue.Enqueue(j)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 提交任务失败: %w", errUnavailable, err)
+	}
+	zap.S().Infof("任务已加入队列: id=%s, name=%s, priority=%d, 前面还有 %d 个任务", j.ID, j.Name, j.Priority, ahead)
+	// 返回任务副本，释放锁后执行协程可能已开始修改队列中的任务
+	return &enqueueResult{job: s.queue.Get(j.ID), ahead: ahead}, nil, nil
+}
+
+// startWorker 启动任务执行协程，按队列顺序逐个执行任务，返回的函数用于停止并等待其退出
+func (s *HTTPServer) startWorker() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			j, err := s.queue.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			zap.S().Infof("开始执行任务: id=%s, name=%s", j.ID, j.Name)
+			err = s.runJob(ctx, j)
+			if ctx.Err() != nil {
+				// 服务停止时任务保持执行中状态，重启后重新执行
+				zap.S().Warnf("服务停止，任务中断: id=%s", j.ID)
+				return
+			}
+			if err != nil {
+				zap.S().Errorf("任务执行失败: id=%s, %v", j.ID, err)
+			}
+			if err := s.queue.Finish(j.ID, err); err != nil {
+				zap.S().Errorf("更新任务状态失败: %v", err)
+			}
 		}
 	}()
 
-	return nil
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runJob 执行通知任务，按升级层级依次通知
+// mode 为 sms_call 时先发送告警短信再拨打电话，为 sms 时只发送短信
+func (s *HTTPServer) runJob(ctx context.Context, j *job.Job) error {
+	if s.queue.CancelRequested(j.ID) {
+		return job.ErrCancelled
+	}
+	if !s.ec600n.IsConnected() {
+		return fmt.Errorf("EC600N 模块未连接")
+	}
+
+	// 值班表与联系人在任务开始执行时解析，排队期间发生交接或进入免打扰时段时以执行时为准
+	tiers, repeat := jobTiers(j)
+	tiers = s.resolveTargets(tiers, time.Now())
+	numbers := tierNumbers(tiers)
+
+	// 先登记为当前任务再执行其他操作，此后的取消请求可以立即中止拨号
+	run := newCallRun(ctx, j.Title(), numbers)
+	run.jobID = j.ID
+	s.callMu.Lock()
+	s.current = run
+	s.callMu.Unlock()
+	defer func() {
+		run.cancel()
+		s.callMu.Lock()
+		s.current = nil
+		s.callMu.Unlock()
+	}()
+
+	if err := s.queue.SetPhoneNumbers(j.ID, numbers); err != nil {
+		zap.S().Errorf("记录任务号码失败: %v", err)
+	}
+	if !j.NotBefore.IsZero() {
+		// 分组窗口结束，通知包含窗口内合并的全部告警
+		j.PhoneNumbers = numbers
+		s.sendWechatNotification(j, 0)
+	}
+
+	callDuration := s.config.EC600N.CallDuration
+	if callDuration <= 0 {
+		callDuration = 10
+	}
+
+	return s.runEscalation(run, j, tiers, repeat, callDuration)
+}
+
+// cancelJob 取消任务：排队中的任务不再执行，执行中的任务停止后续拨号并挂断当前通话
+func (s *HTTPServer) cancelJob(id string) (*job.Job, error) {
+	j, err := s.queue.Cancel(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Status == job.StatusRunning {
+		if run := s.currentRun(); run != nil && run.jobID == id {
+			run.abort()
+			if err := s.ec600n.HangupCall(); err != nil {
+				zap.S().Errorf("挂断当前通话失败: %v", err)
+			}
+		}
+	}
+	zap.S().Infof("任务已取消: id=%s, name=%s", j.ID, j.Name)
+	return s.queue.Get(id), nil
 }
 
 // handleNotify 处理 /api/nofity 请求
@@ -297,6 +736,8 @@ func (s *HTTPServer) handleNotify(w http.ResponseWriter, r *http.Request) {
 		statusCode := http.StatusBadRequest
 		if strings.Contains(err.Error(), "签名验证失败") || strings.Contains(err.Error(), "时间戳验证失败") {
 			statusCode = http.StatusUnauthorized
+		} else if errors.Is(err, errForbidden) || errors.Is(err, errRateLimited) {
+			statusCode = authStatus(err)
 		} else if strings.Contains(err.Error(), "method not allowed") {
 			statusCode = http.StatusMethodNotAllowed
 		}
@@ -304,27 +745,151 @@ func (s *HTTPServer) handleNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	zap.S().Infof("API请求验证成功: name=%s, phoneNumbers=%s, timestamp=%s",
-		req.Name, req.PhoneNumbers, req.Timestamp)
+	zap.S().Infof("API请求验证成功: client=%s, name=%s, phoneNumbers=%s, contacts=%v, mode=%s, escalation=%s, schedule=%s, timestamp=%s",
+		req.ClientID, req.Name, req.PhoneNumbers, req.Contacts, req.Mode, req.Escalation, req.Schedule, req.Timestamp)
+
+	// 任务未提交时返回失败：限流返回 429，EC600N 未就绪或任务保存失败返回 503 由调用方重试，其他为请求错误
+	result, err := s.enqueueNotify(req)
+	if err != nil {
+		statusCode := http.StatusBadRequest
+		switch {
+		case errors.Is(err, errRateLimited):
+			statusCode = http.StatusTooManyRequests
+		case errors.Is(err, errUnavailable):
+			statusCode = http.StatusServiceUnavailable
+		}
+		s.writeErrorResponse(w, statusCode, fmt.Sprintf("提交通知任务失败: %s", err.Error()))
+		return
+	}
+
+	response := NotifyResponse{Success: true, Message: "验证成功", JobID: result.job.ID}
+	switch {
+	case result.duplicate:
+		response.Message = "重复告警，已忽略"
+	case result.merged:
+		response.Message = fmt.Sprintf("已合并到任务，共 %d 条告警", len(result.job.Alerts))
+	case result.ahead > 0:
+		response.Message = fmt.Sprintf("已加入任务队列，前面还有 %d 个任务", result.ahead)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAlertmanager 处理 /api/alertmanager 请求，接收 Prometheus Alertmanager webhook
+func (s *HTTPServer) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhook(w, r, "Alertmanager", &s.config.Alertmanager, parseAlertmanager)
+}
+
+// handleGrafana 处理 /api/grafana 请求，接收 Grafana 统一告警 webhook
+func (s *HTTPServer) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhook(w, r, "Grafana", &s.config.Grafana, parseGrafana)
+}
+
+// handleZabbix 处理 /api/zabbix 请求，接收 Zabbix 媒介类型 webhook
+func (s *HTTPServer) handleZabbix(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhook(w, r, "Zabbix", &s.config.Zabbix.WebhookReceiver, s.parseZabbix)
+}
+
+// handleListSMS 处理 /api/sms 请求，返回最近收到的短信
+// 可选参数 limit 指定返回条数，默认 50
+func (s *HTTPServer) handleListSMS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		s.writeErrorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if _, err := s.authenticateQuery(r, ScopeSMS); err != nil {
+		s.writeErrorResponse(w, authStatus(err), err.Error())
+		return
+	}
+
+	limit := DefaultSMSListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			s.writeErrorResponse(w, http.StatusBadRequest, "limit 参数无效")
+			return
+		}
+		limit = n
+	}
 
-	message := "验证成功"
-	if err := s.processPhoneCalls(req.Name, req.PhoneNumbers); err != nil {
-		if strings.Contains(err.Error(), "已有任务在处理") {
-			s.writeErrorResponse(w, http.StatusServiceUnavailable, "已有任务在处理")
+	messages := []*ec600n.SMSMessage{}
+	if s.ec600n != nil && s.ec600n.Inbox() != nil {
+		messages = s.ec600n.Inbox().List(limit)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SMSListResponse{Success: true, Messages: messages})
+}
+
+// handleJob 处理 /api/jobs/{id} 请求
+// GET 查询任务状态与每次呼叫的结果；DELETE 或 POST /api/jobs/{id}/cancel 取消任务
+func (s *HTTPServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	if id == "" || (action != "" && action != "cancel") {
+		s.writeErrorResponse(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	cancel := false
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+	case action == "" && r.Method == http.MethodDelete, action == "cancel" && r.Method == http.MethodPost:
+		cancel = true
+	default:
+		s.writeErrorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	client, err := s.authenticateQuery(r, ScopeStatus)
+	if err != nil {
+		s.writeErrorResponse(w, authStatus(err), err.Error())
+		return
+	}
+
+	if !cancel {
+		j := s.queue.Get(id)
+		if j == nil {
+			s.writeErrorResponse(w, http.StatusNotFound, "任务不存在")
 			return
 		}
-		message = fmt.Sprintf("拨打电话失败: %s", err.Error())
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(JobResponse{Success: true, Job: j})
+		return
+	}
+
+	// 只有 admin 权限的客户端可以取消其他客户端提交的任务
+	if existing := s.queue.Get(id); existing != nil && !client.allow(ScopeAdmin) && existing.Client != client.id {
+		s.writeErrorResponse(w, http.StatusForbidden, "权限不足: 只能取消本客户端提交的任务")
+		return
+	}
+
+	j, err := s.cancelJob(id)
+	switch {
+	case errors.Is(err, job.ErrNotFound):
+		s.writeErrorResponse(w, http.StatusNotFound, "任务不存在")
+		return
+	case errors.Is(err, job.ErrFinished):
+		s.writeErrorResponse(w, http.StatusConflict, "任务已结束，无法取消")
+		return
+	case err != nil:
+		s.writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(NotifyResponse{Success: true, Message: message})
+	json.NewEncoder(w).Encode(JobResponse{Success: true, Message: "任务已取消", Job: j})
 }
 
 // ProvideHTTPServer 提供HTTP服务器依赖注入
 func ProvideHTTPServer() fx.Option {
 	return fx.Options(
 		fx.Provide(NewHTTPServer),
-		fx.Invoke(registerHTTPServerLifecycle),
+		fx.Invoke(validateSecrets, registerHTTPServerLifecycle),
 	)
 }
 
@@ -332,6 +897,12 @@ func ProvideHTTPServer() fx.Option {
 func registerHTTPServerLifecycle(lifecycle fx.Lifecycle, server *HTTPServer) {
 	lifecycle.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
+			// 监听告警确认（短信回复、回拨）
+			if server.ec600n != nil {
+				server.stopAckWatch = server.watchAcks()
+				server.stopWorker = server.startWorker()
+			}
+
 			// 在goroutine中启动HTTP服务器
 			go func() {
 				zap.S().Infof("启动HTTP服务器，监听端口: %s", server.server.Addr)
@@ -342,6 +913,13 @@ func registerHTTPServerLifecycle(lifecycle fx.Lifecycle, server *HTTPServer) {
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			if server.stopAckWatch != nil {
+				server.stopAckWatch()
+			}
+			if server.stopWorker != nil {
+				server.stopWorker()
+			}
+
 			zap.S().Info("正在关闭HTTP服务器...")
 			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
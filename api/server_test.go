@@ -2,8 +2,11 @@ package api
 
 import (
 	"alert-mobile-notify/config"
+	"alert-mobile-notify/contact"
+	"alert-mobile-notify/job"
 	"alert-mobile-notify/notification"
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -17,15 +20,38 @@ import (
 	"time"
 )
 
+// newTestQueue 创建仅保存在内存中的任务队列
+func newTestQueue(t *testing.T) *job.Queue {
+	queue, err := job.NewQueue(nil)
+	assert.NoError(t, err)
+	return queue
+}
+
+// recordNotifier 记录发送消息的通知渠道
+type recordNotifier struct {
+	messages []*notification.Message
+}
+
+func (n *recordNotifier) Name() string { return "record" }
+
+func (n *recordNotifier) Send(msg *notification.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
 // TestHandleNotify_MillisecondTimestamp 测试毫秒级时间戳
 func TestHandleNotify_MillisecondTimestamp(t *testing.T) {
 
 	cfg, err := config.LoadConfig("../config.yaml")
 	assert.NoError(t, err)
 
+	cfg.API.LegacySignature = true
+
+	// 日志写入临时目录，避免在包目录下生成日志文件
+	cfg.Logger.Path = t.TempDir() + "/"
 	config.InitLogger(cfg)
 	notify := notification.NewWechatNotify(cfg)
-	server := NewHTTPServer(cfg, nil, notify)
+	server := NewHTTPServer(cfg, nil, notify, newTestQueue(t), nil, nil)
 
 	// 创建HTTP测试服务器
 	ts := httptest.NewServer(server.server.Handler)
@@ -51,9 +77,9 @@ func TestHandleNotify_MillisecondTimestamp(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	// 验证响应
-	if resp.StatusCode != http.StatusOK {
-		t.Errorf("期望状态码 %d, 得到 %d", http.StatusOK, resp.StatusCode)
+	// 验证响应：签名验证通过，EC600N 未启用时任务无法提交，返回 503 由调用方重试
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("期望状态码 %d, 得到 %d", http.StatusServiceUnavailable, resp.StatusCode)
 	}
 
 	var response NotifyResponse
@@ -61,8 +87,23 @@ func TestHandleNotify_MillisecondTimestamp(t *testing.T) {
 		t.Fatalf("解析响应失败: %v", err)
 	}
 
-	if !response.Success {
-		t.Error("期望 Success 为 true")
+	if response.Success || response.JobID != "" {
+		t.Error("期望 Success 为 false 且没有任务 ID")
+	}
+
+	// 电话号码为空时任务无法提交，返回 400
+	body, _ = json.Marshal(NotifyRequest{
+		Name:      "test",
+		Timestamp: timestamp,
+		Signature: generateSignature("test", "", timestamp, cfg.API.SecretKey),
+	})
+	resp2, err := http.Post(ts.URL+"/api/nofity", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("HTTP请求失败: %v", err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("期望状态码 %d, 得到 %d", http.StatusBadRequest, resp2.StatusCode)
 	}
 }
 
@@ -104,6 +145,62 @@ func TestHttpNotify_MillisecondTimestamp(t *testing.T) {
 	}
 }
 
+// TestHandleListSMS 测试短信列表接口签名验证
+func TestHandleListSMS(t *testing.T) {
+	cfg, err := config.LoadConfig("../config.yaml")
+	assert.NoError(t, err)
+	cfg.API.LegacySignature = true
+
+	server := NewHTTPServer(cfg, nil, notification.NewWechatNotify(cfg), newTestQueue(t), nil, nil)
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+
+	// 签名错误
+	resp, err := http.Get(ts.URL + "/api/sms?timestamp=" + timestamp + "&signature=bad")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// 签名正确，EC600N 未启用时返回空列表
+	hash := md5.Sum([]byte(fmt.Sprintf("secretKey=%s&timestamp=%s", cfg.API.SecretKey, timestamp)))
+	signature := hex.EncodeToString(hash[:])
+	resp, err = http.Get(ts.URL + "/api/sms?timestamp=" + timestamp + "&signature=" + signature)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var response SMSListResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.True(t, response.Success)
+	assert.Empty(t, response.Messages)
+}
+
+// TestCallRunAcknowledge 测试告警确认匹配与停止拨号
+func TestCallRunAcknowledge(t *testing.T) {
+	server := &HTTPServer{config: &config.Config{}}
+	assert.True(t, server.isAckMessage(" ack "))
+	assert.True(t, server.isAckMessage("1"))
+	assert.False(t, server.isAckMessage("收到"))
+
+	run := newCallRun(context.Background(), "test", []string{"138-0013-8000", "13900139000"})
+	matched, ok := run.matchNumber("+8613800138000")
+	assert.True(t, ok)
+	assert.Equal(t, "138-0013-8000", matched)
+	_, ok = run.matchNumber("13700137000")
+	assert.False(t, ok)
+
+	ack, first := run.acknowledge(matched, AckMethodSMS)
+	assert.True(t, first)
+	assert.Equal(t, AckMethodSMS, ack.Method)
+	assert.Error(t, run.ctx.Err())
+
+	_, first = run.acknowledge("13900139000", AckMethodCallback)
+	assert.False(t, first)
+	assert.Equal(t, "138-0013-8000", run.acked().Number)
+}
+
 // generateSignature 生成签名（用于测试）
 func generateSignature(name, phoneNumbers, timestamp, secretKey string) string {
 	params := map[string]string{
@@ -128,3 +225,488 @@ func generateSignature(name, phoneNumbers, timestamp, secretKey string) string {
 	hash := md5.Sum([]byte(signString))
 	return hex.EncodeToString(hash[:])
 }
+
+func TestHandleJob(t *testing.T) {
+	cfg, err := config.LoadConfig("../config.yaml")
+	assert.NoError(t, err)
+	cfg.API.LegacySignature = true
+
+	queue := newTestQueue(t)
+	server := NewHTTPServer(cfg, nil, notification.NewWechatNotify(cfg), queue, nil, nil)
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	j := &job.Job{Name: "test", PhoneNumbers: []string{"13800138000"}, Mode: NotifyModeCall, Client: LegacyClientID}
+	_, err = queue.Enqueue(j)
+	assert.NoError(t, err)
+
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	hash := md5.Sum([]byte(fmt.Sprintf("secretKey=%s&timestamp=%s", cfg.API.SecretKey, timestamp)))
+	query := "?timestamp=" + timestamp + "&signature=" + hex.EncodeToString(hash[:])
+
+	do := func(method, path string) (*http.Response, JobResponse) {
+		req, err := http.NewRequest(method, ts.URL+path+query, nil)
+		assert.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var response JobResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return resp, response
+	}
+
+	resp, response := do(http.MethodGet, "/api/jobs/"+j.ID)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, job.StatusPending, response.Job.Status)
+	assert.Equal(t, []string{"13800138000"}, response.Job.PhoneNumbers)
+
+	resp, _ = do(http.MethodGet, "/api/jobs/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodPut, "/api/jobs/"+j.ID)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// 旧版签名的客户端不能取消其他客户端提交的任务
+	other := &job.Job{Name: "other", PhoneNumbers: []string{"13800138000"}, Mode: NotifyModeCall, Client: DefaultClientID}
+	_, err = queue.Enqueue(other)
+	assert.NoError(t, err)
+	resp, _ = do(http.MethodDelete, "/api/jobs/"+other.ID)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err = queue.Cancel(other.ID)
+	assert.NoError(t, err)
+
+	// 排队中的任务直接取消
+	resp, response = do(http.MethodPost, "/api/jobs/"+j.ID+"/cancel")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, job.StatusCancelled, response.Job.Status)
+
+	resp, _ = do(http.MethodDelete, "/api/jobs/"+j.ID)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, 0, queue.Pending())
+
+	// 任务已开始执行但尚未拨号时取消，执行方不再拨号并以已取消结束
+	running := &job.Job{Name: "running", PhoneNumbers: []string{"13800138000"}, Mode: NotifyModeCall, Client: LegacyClientID}
+	_, err = queue.Enqueue(running)
+	assert.NoError(t, err)
+	_, err = queue.Next(context.Background())
+	assert.NoError(t, err)
+	resp, _ = do(http.MethodDelete, "/api/jobs/"+running.ID)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	err = server.runJob(context.Background(), queue.Get(running.ID))
+	assert.ErrorIs(t, err, job.ErrCancelled)
+	assert.NoError(t, queue.Finish(running.ID, err))
+	assert.Equal(t, job.StatusCancelled, queue.Get(running.ID).Status)
+}
+
+func TestResolveEscalation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Escalation.Policies = map[string]config.EscalationPolicy{
+		"ops": {
+			Repeat: 2,
+			Tiers: []config.EscalationTier{
+				{Numbers: []string{"13800138000"}, Wait: 5},
+				{Numbers: []string{" 13900139000", "", "+8613800138000"}, Wait: 10},
+			},
+		},
+		"empty": {Tiers: []config.EscalationTier{{Wait: 5}}},
+	}
+	server := &HTTPServer{config: cfg}
+
+	// 未指定策略时 phoneNumbers 作为单一层级拨打一轮
+	tiers, repeat, err := server.resolveEscalation(&NotifyRequest{PhoneNumbers: "13800138000, 13900139000"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, repeat)
+	assert.Equal(t, []job.Tier{{Numbers: []string{"13800138000", "13900139000"}}}, tiers)
+
+	_, _, err = server.resolveEscalation(&NotifyRequest{})
+	assert.Error(t, err)
+
+	tiers, repeat, err = server.resolveEscalation(&NotifyRequest{Escalation: "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, repeat)
+	assert.Len(t, tiers, 2)
+	assert.Equal(t, 10, tiers[1].Wait)
+	assert.Equal(t, []string{"13800138000", "13900139000"}, tierNumbers(tiers))
+
+	_, _, err = server.resolveEscalation(&NotifyRequest{Escalation: "empty"})
+	assert.Error(t, err)
+	_, _, err = server.resolveEscalation(&NotifyRequest{Escalation: "missing"})
+	assert.Error(t, err)
+}
+
+func TestResolveTargets(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Contacts.People = map[string]config.ContactPerson{
+		"zhangsan": {Name: "张三", Phones: []string{"13800138000"}, WechatUserID: "zhangsan"},
+		"lisi":     {Name: "李四", Phones: []string{"13900139000"}},
+	}
+	cfg.Contacts.Teams = map[string]config.ContactTeam{"dba": {Members: []string{"zhangsan", "lisi"}}}
+	directory, err := contact.NewDirectory(cfg)
+	assert.NoError(t, err)
+	server := &HTTPServer{config: cfg, contacts: directory}
+
+	tiers, _, err := server.resolveEscalation(&NotifyRequest{PhoneNumbers: "13700137000", Contacts: []string{"team:dba", "zhangsan"}})
+	assert.NoError(t, err)
+	resolved := server.resolveTargets(tiers, time.Now())
+	assert.Equal(t, []string{"13700137000", "13800138000", "13900139000"}, resolved[0].Numbers)
+
+	numbers, mentions, mobiles := server.describeNumbers(resolved[0].Numbers)
+	assert.Equal(t, "13700137000, 张三 13800138000, 李四 13900139000", numbers)
+	assert.Equal(t, []string{"zhangsan"}, mentions)
+	assert.Equal(t, []string{"13700137000", "13900139000"}, mobiles)
+
+	_, _, err = server.resolveEscalation(&NotifyRequest{Contacts: []string{"team:ops"}})
+	assert.Error(t, err)
+}
+
+func TestHandleAlertmanager(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Alertmanager.BearerToken = "token"
+	cfg.Alertmanager.Routes = []config.AlertRoute{
+		{Match: map[string]string{"severity": "critical", "team": "dba"}, PhoneNumbers: []string{"13800138000"}},
+		{Match: map[string]string{"severity": "critical"}, Escalation: "missing"},
+	}
+	notify := &recordNotifier{}
+	server := NewHTTPServer(cfg, nil, notify, newTestQueue(t), nil, nil)
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	route := matchAlertRoute(cfg.Alertmanager.Routes, map[string]string{"severity": "critical", "team": "dba", "instance": "db1"})
+	assert.Equal(t, []string{"13800138000"}, route.PhoneNumbers)
+	route = matchAlertRoute(cfg.Alertmanager.Routes, map[string]string{"severity": "critical", "team": "web"})
+	assert.Equal(t, "missing", route.Escalation)
+	assert.Nil(t, matchAlertRoute(cfg.Alertmanager.Routes, map[string]string{"severity": "warning"}))
+
+	alert := AlertmanagerAlert{
+		Labels:      map[string]string{"alertname": "MySQLDown", "severity": "critical", "team": "dba"},
+		Annotations: map[string]string{"summary": "db1 不可用"},
+	}
+	assert.Equal(t, "MySQLDown: db1 不可用", alertName(&alert))
+	assert.Equal(t, "severity=critical, team=dba", formatLabels(alert.Labels))
+
+	// 去重键使用 fingerprint，未携带时使用告警名称与标签，不受注解影响
+	assert.Equal(t, "MySQLDown{severity=critical, team=dba}", alert.toWebhookAlert().DedupKey)
+	changed := alert
+	changed.Annotations = map[string]string{"summary": "db1 连接数过高"}
+	assert.Equal(t, alert.toWebhookAlert().DedupKey, changed.toWebhookAlert().DedupKey)
+	changed.Fingerprint = "c6c8d1b0f2a3e4d5"
+	assert.Equal(t, "c6c8d1b0f2a3e4d5", changed.toWebhookAlert().DedupKey)
+
+	post := func(token string, msg AlertmanagerMessage) (*http.Response, WebhookResponse) {
+		body, _ := json.Marshal(msg)
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/alertmanager", bytes.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var response WebhookResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+		return resp, response
+	}
+
+	resp, _ := post("bad", AlertmanagerMessage{})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// EC600N 未启用时 firing 告警提交失败，返回 503 由 Alertmanager 重试；resolved 告警只发送恢复通知
+	firing, resolved := alert, alert
+	firing.Status, resolved.Status = AlertStatusFiring, AlertStatusResolved
+	resp, response := post("token", AlertmanagerMessage{Version: "4", Status: AlertStatusFiring, Alerts: []AlertmanagerAlert{firing, resolved}})
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.False(t, response.Success)
+	assert.Empty(t, response.JobIDs)
+	assert.Contains(t, response.Message, "EC600N")
+
+	resp, response = post("token", AlertmanagerMessage{Version: "4", Status: AlertStatusResolved, Alerts: []AlertmanagerAlert{resolved}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, response.Success)
+
+	// 未匹配路由或升级策略不存在的告警重试也无法提交，返回 200 与 success:false，并发送未能提交的提醒
+	unrouted := firing
+	unrouted.Labels = map[string]string{"alertname": "HighLoad", "severity": "warning"}
+	invalid := firing
+	invalid.Labels = map[string]string{"alertname": "Nginx5xx", "severity": "critical", "team": "web"}
+	notify.messages = nil
+	resp, response = post("token", AlertmanagerMessage{Version: "4", Status: AlertStatusFiring, Alerts: []AlertmanagerAlert{unrouted, invalid}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.False(t, response.Success)
+	assert.Contains(t, response.Message, "未匹配通知路由")
+	assert.Contains(t, response.Message, "升级策略不存在")
+	if assert.Len(t, notify.messages, 2) {
+		assert.Equal(t, "HighLoad: db1 不可用", notify.messages[0].Fields[0].Value)
+		assert.Equal(t, "Nginx5xx: db1 不可用", notify.messages[1].Fields[0].Value)
+	}
+}
+
+func TestParseGrafanaAndZabbix(t *testing.T) {
+	body := `{"receiver":"phone","status":"firing","alerts":[{"status":"firing","labels":{"alertname":"HighCPU","team":"ops"},"annotations":{},"dashboardURL":"http://grafana/d/1"},{"status":"resolved","labels":{"alertname":"DiskFull"}}],"title":"[FIRING:1] HighCPU"}`
+	alerts, err := parseGrafana(json.NewDecoder(strings.NewReader(body)))
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, "HighCPU", alerts[0].Name)
+	assert.Equal(t, "ops", alerts[0].Labels["team"])
+	assert.False(t, alerts[0].Resolved)
+	assert.True(t, alerts[1].Resolved)
+
+	cfg := &config.Config{}
+	cfg.Zabbix.Fields.Name = "title"
+	cfg.Zabbix.Fields.Severity = "level"
+	server := &HTTPServer{config: cfg}
+
+	body = `{"title":"Zabbix agent unreachable","status":"PROBLEM","level":"High","eventid":1024,"tags":[{"tag":"team","value":"dba"}]}`
+	alerts, err = server.parseZabbix(json.NewDecoder(strings.NewReader(body)))
+	assert.NoError(t, err)
+	assert.Equal(t, "Zabbix agent unreachable", alerts[0].Name)
+	assert.Equal(t, "High", alerts[0].Labels["severity"])
+	assert.Equal(t, "dba", alerts[0].Labels["team"])
+	assert.Equal(t, "1024", alerts[0].Labels["eventid"])
+	assert.False(t, alerts[0].Resolved)
+
+	alerts, err = server.parseZabbix(json.NewDecoder(strings.NewReader(`{"title":"Zabbix agent unreachable","status":"Resolved"}`)))
+	assert.NoError(t, err)
+	assert.True(t, alerts[0].Resolved)
+
+	_, err = server.parseZabbix(json.NewDecoder(strings.NewReader(`{"status":"PROBLEM"}`)))
+	assert.Error(t, err)
+}
+
+func TestParseHook(t *testing.T) {
+	hook := &config.Hook{
+		Name:           "{{.monitor.name}} 不可用",
+		Severity:       "$.level",
+		Contacts:       "$.owners[*]",
+		DedupKey:       "$.monitor.id",
+		Status:         "$.status",
+		ResolvedValues: []string{"up"},
+		Labels:         map[string]string{"team": "$.monitor.team"},
+	}
+	decode := func(body string) []*webhookAlert {
+		alerts, err := parseHook(hook, json.NewDecoder(strings.NewReader(body)))
+		assert.NoError(t, err)
+		return alerts
+	}
+
+	alerts := decode(`{"monitor":{"id":42,"name":"api","team":"dba"},"level":"critical","owners":["zhangsan","team:dba"],"status":"down"}`)
+	assert.Equal(t, "api 不可用", alerts[0].Name)
+	assert.Equal(t, map[string]string{"severity": "critical", "team": "dba"}, alerts[0].Labels)
+	assert.Equal(t, "42", alerts[0].DedupKey)
+	assert.Equal(t, []string{"zhangsan", "team:dba"}, alerts[0].Route.Contacts)
+	assert.False(t, alerts[0].Resolved)
+
+	// 未取到联系人时按路由匹配
+	alerts = decode(`{"monitor":{"id":42,"name":"api"},"status":"UP"}`)
+	assert.Nil(t, alerts[0].Route)
+	assert.True(t, alerts[0].Resolved)
+
+	_, err := parseHook(&config.Hook{Name: "$.missing"}, json.NewDecoder(strings.NewReader(`{}`)))
+	assert.Error(t, err)
+
+	// 认证方式
+	req := httptest.NewRequest(http.MethodPost, "/api/hooks/uptime", nil)
+	req.Header.Set("X-Auth-Token", "secret")
+	assert.NoError(t, authenticateWebhook(req, &config.WebhookReceiver{Auth: WebhookAuthHeader, Token: "secret"}))
+	assert.Error(t, authenticateWebhook(req, &config.WebhookReceiver{Auth: WebhookAuthHeader, Token: "other"}))
+	assert.Error(t, authenticateWebhook(req, &config.WebhookReceiver{}))
+	assert.NoError(t, authenticateWebhook(req, &config.WebhookReceiver{Auth: WebhookAuthNone}))
+}
+
+func TestSignatureV2(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.API.SecretKey = "secret"
+	server := NewHTTPServer(cfg, nil, notification.NewWechatNotify(cfg), newTestQueue(t), nil, nil)
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	do := func(method, path, nonce string, body []byte, secret string) int {
+		timestamp := fmt.Sprintf("%d", time.Now().UnixMilli())
+		req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderNonce, nonce)
+		req.Header.Set(HeaderSignature, signV2(secret, timestamp, nonce, method, path, body))
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 签名验证通过，EC600N 未启用时任务无法提交，返回 503
+	body, _ := json.Marshal(NotifyRequest{Name: "test", PhoneNumbers: "13800138000"})
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodPost, "/api/nofity", "nonce-0001", body, "secret"))
+	// 重放相同随机串
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/nofity", "nonce-0001", body, "secret"))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/nofity", "nonce-0002", body, "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/nofity", "short", body, "secret"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/sms?limit=10", "nonce-0003", nil, "secret"))
+
+	// 未开启旧版签名时拒绝 MD5 签名
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	body, _ = json.Marshal(NotifyRequest{
+		Name:         "test",
+		PhoneNumbers: "13800138000",
+		Timestamp:    timestamp,
+		Signature:    generateSignature("test", "13800138000", timestamp, "secret"),
+	})
+	resp, err := http.Post(ts.URL+"/api/nofity", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// 开启旧版签名时，拒绝携带签名未覆盖字段的请求，避免重放时篡改通知对象
+	cfg.API.LegacySignature = true
+	body, _ = json.Marshal(NotifyRequest{
+		Name:         "test",
+		PhoneNumbers: "13800138000",
+		Contacts:     []string{"zhangsan"},
+		Escalation:   "dba",
+		Timestamp:    timestamp,
+		Signature:    generateSignature("test", "13800138000", timestamp, "secret"),
+	})
+	resp, err = http.Post(ts.URL+"/api/nofity", "application/json", bytes.NewReader(body))
+	assert.NoError(t, err)
+	var response NotifyResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, response.Message, "escalation, contacts")
+
+	cache := newNonceCache(time.Minute)
+	now := time.Now()
+	assert.True(t, cache.use("a", now))
+	assert.False(t, cache.use("a", now.Add(30*time.Second)))
+	assert.True(t, cache.use("a", now.Add(2*time.Minute)))
+}
+
+func TestAPIClients(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.API.SecretKey = "secret"
+	cfg.API.Clients = []config.APIClient{
+		{ID: "zabbix", Secrets: []string{"old", "new"}, Scopes: []string{ScopeSMS, ScopeStatus}, AllowedTeams: []string{"dba"}, RateLimit: 2},
+	}
+	cfg.Contacts.People = map[string]config.ContactPerson{
+		"zhangsan": {Phones: []string{"13800138000"}},
+		"lisi":     {Phones: []string{"13900139000"}},
+	}
+	cfg.Contacts.Teams = map[string]config.ContactTeam{"dba": {Members: []string{"zhangsan"}}}
+	directory, err := contact.NewDirectory(cfg)
+	assert.NoError(t, err)
+	server := NewHTTPServer(cfg, nil, notification.NewWechatNotify(cfg), newTestQueue(t), nil, directory)
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	nonce := 0
+	do := func(client, secret string, req NotifyRequest) int {
+		nonce++
+		body, _ := json.Marshal(req)
+		timestamp := fmt.Sprintf("%d", time.Now().Unix())
+		n := fmt.Sprintf("nonce-%04d", nonce)
+		r, err := http.NewRequest(http.MethodPost, ts.URL+"/api/nofity", bytes.NewReader(body))
+		assert.NoError(t, err)
+		r.Header.Set(HeaderClientID, client)
+		r.Header.Set(HeaderTimestamp, timestamp)
+		r.Header.Set(HeaderNonce, n)
+		r.Header.Set(HeaderSignature, signV2(secret, timestamp, n, http.MethodPost, "/api/nofity", body))
+		resp, err := http.DefaultClient.Do(r)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 轮换期间新旧密钥均有效；验证通过后 EC600N 未启用，任务无法提交时返回 503
+	assert.Equal(t, http.StatusServiceUnavailable, do("zabbix", "old", NotifyRequest{Name: "test", Mode: NotifyModeSMS, Contacts: []string{"team:dba"}}))
+	assert.Equal(t, http.StatusServiceUnavailable, do("zabbix", "new", NotifyRequest{Name: "test", Mode: NotifyModeSMS, Contacts: []string{"zhangsan"}}))
+	assert.Equal(t, http.StatusUnauthorized, do("zabbix", "secret", NotifyRequest{Name: "test", Mode: NotifyModeSMS, Contacts: []string{"zhangsan"}}))
+	// 缺少 call 权限、通知对象不在允许范围内
+	assert.Equal(t, http.StatusForbidden, do("zabbix", "new", NotifyRequest{Name: "test", Contacts: []string{"zhangsan"}}))
+	assert.Equal(t, http.StatusForbidden, do("zabbix", "new", NotifyRequest{Name: "test", Mode: NotifyModeSMS, Contacts: []string{"lisi"}}))
+	assert.Equal(t, http.StatusForbidden, do("zabbix", "new", NotifyRequest{Name: "test", Mode: NotifyModeSMS, PhoneNumbers: "13700137000"}))
+	// 超过每分钟请求数
+	assert.Equal(t, http.StatusTooManyRequests, do("zabbix", "new", NotifyRequest{Name: "test", Mode: NotifyModeSMS, Contacts: []string{"team:dba"}}))
+	// default 客户端拥有全部权限
+	assert.Equal(t, http.StatusServiceUnavailable, do("", "secret", NotifyRequest{Name: "test", PhoneNumbers: "13700137000"}))
+
+	// 密钥为示例占位值时拒绝启动
+	assert.NoError(t, validateSecrets(cfg))
+	cfg.API.Clients[0].Secrets = []string{"new", " Change-Me "}
+	assert.Error(t, validateSecrets(cfg))
+	shipped, err := config.LoadConfig("../config.yaml")
+	assert.NoError(t, err)
+	assert.NoError(t, validateSecrets(shipped))
+}
+
+func TestCallLimiter(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Limits.NumberCallsPerHour = 2
+	cfg.Limits.CallsPerHour = 3
+	cfg.Limits.AlertCooldown = 10
+	limiter := newCallLimiter(cfg)
+	now := time.Now()
+
+	assert.NoError(t, limiter.admit("disk", []string{"13800138000"}, true, now))
+	// 冷却期内拒绝同名告警
+	err := limiter.admit("disk", []string{"13800138000"}, true, now.Add(time.Minute))
+	assert.ErrorIs(t, err, errRateLimited)
+	assert.NoError(t, limiter.admit("disk", []string{"13800138000"}, true, now.Add(11*time.Minute)))
+
+	assert.NoError(t, limiter.allowCall("13800138000", now))
+	assert.NoError(t, limiter.allowCall("+86 138-0013-8000", now))
+	assert.ErrorIs(t, limiter.allowCall("13800138000", now), errRateLimited)
+	// 号码全部超限时拒绝提交，部分超限时仍然提交
+	assert.ErrorIs(t, limiter.admit("cpu", []string{"13800138000"}, true, now), errRateLimited)
+	assert.NoError(t, limiter.admit("cpu", []string{"13800138000", "13900139000"}, true, now))
+	// 仅发送短信时不检查拨打次数
+	assert.NoError(t, limiter.admit("mem", []string{"13800138000"}, false, now))
+
+	assert.NoError(t, limiter.allowCall("13900139000", now))
+	assert.ErrorIs(t, limiter.allowCall("13700137000", now), errRateLimited)
+	assert.ErrorIs(t, limiter.admit("load", []string{"13700137000"}, true, now), errRateLimited)
+	// 一小时后恢复
+	assert.NoError(t, limiter.allowCall("13800138000", now.Add(time.Hour)))
+}
+
+func TestDedupAndGroupKey(t *testing.T) {
+	server := &HTTPServer{config: &config.Config{}}
+	assert.Equal(t, "", server.dedupKey(&NotifyRequest{Name: "disk"}))
+	assert.Equal(t, "host-1", server.dedupKey(&NotifyRequest{Name: "disk", DedupKey: " host-1 "}))
+	server.config.Dedup.Window = 5
+	assert.Equal(t, "disk", server.dedupKey(&NotifyRequest{Name: "disk"}))
+
+	// 号码与联系人顺序不影响分组
+	a := notifyGroupKey(&NotifyRequest{Name: "disk", PhoneNumbers: "13800138000,+86 139-0013-9000", Contacts: []string{"lisi", "zhangsan"}})
+	b := notifyGroupKey(&NotifyRequest{Name: "cpu", PhoneNumbers: "13900139000, 13800138000", Contacts: []string{"zhangsan", "lisi"}})
+	assert.Equal(t, a, b)
+	c := notifyGroupKey(&NotifyRequest{Name: "cpu", PhoneNumbers: "13800138000", Mode: NotifyModeSMS})
+	assert.NotEqual(t, a, c)
+}
+
+func TestAdmitNotify_MergeGroup(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Dedup.GroupWindow = 30
+	cfg.Limits.AlertCooldown = 10
+	server := NewHTTPServer(cfg, nil, &recordNotifier{}, newTestQueue(t), nil, nil)
+	tiers := []job.Tier{{Numbers: []string{"13800138000"}}}
+	now := time.Now()
+
+	first, _, err := server.admitNotify(&NotifyRequest{Name: "disk", PhoneNumbers: "13800138000", Mode: NotifyModeCall, Priority: 1, Severity: "warning"}, tiers, 1, now)
+	assert.NoError(t, err)
+
+	// 优先级与级别更高的告警合并到分组任务时提升任务的优先级与级别
+	result, _, err := server.admitNotify(&NotifyRequest{Name: "mysql", PhoneNumbers: "13800138000", Mode: NotifyModeCall, Priority: 5, Severity: "critical"}, tiers, 1, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.True(t, result.merged)
+	assert.Equal(t, first.job.ID, result.job.ID)
+	assert.Equal(t, 5, result.job.Priority)
+	assert.Equal(t, "critical", result.job.Severity)
+
+	// 较低的优先级与级别不降低任务的优先级与级别
+	result, _, err = server.admitNotify(&NotifyRequest{Name: "cpu", PhoneNumbers: "13800138000", Mode: NotifyModeCall, Severity: "info"}, tiers, 1, now.Add(2*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.job.Priority)
+	assert.Equal(t, "critical", result.job.Severity)
+
+	// 合并的告警同样记录冷却时间，分组窗口结束后同名告警仍在冷却期内
+	_, _, err = server.admitNotify(&NotifyRequest{Name: "mysql", PhoneNumbers: "13800138000", Mode: NotifyModeCall}, tiers, 1, now.Add(time.Minute))
+	assert.ErrorIs(t, err, errRateLimited)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// HeaderSignature v2 签名请求头，值为十六进制 HMAC-SHA256
+	HeaderSignature = "X-Signature"
+	// HeaderTimestamp v2 签名时间戳请求头（秒或毫秒）
+	HeaderTimestamp = "X-Timestamp"
+	// HeaderNonce v2 签名随机串请求头，容差时间内不可重复使用
+	HeaderNonce = "X-Nonce"
+
+	// MinNonceLength 随机串最小长度
+	MinNonceLength = 8
+	// MaxNonceLength 随机串最大长度
+	MaxNonceLength = 128
+)
+
+// nonceCache 记录容差时间内已使用的随机串，用于拒绝重放请求
+type nonceCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time
+}
+
+// newNonceCache 创建随机串缓存，随机串在 ttl 内不可重复使用
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+// use 记录随机串，ttl 内已使用过时返回 false
+func (c *nonceCache) use(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for n, at := range c.seen {
+		if now.Sub(at) > c.ttl {
+			delete(c.seen, n)
+		}
+	}
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+	c.seen[nonce] = now
+	return true
+}
+
+// signV2 计算 v2 签名
+// 签名串为 timestamp、nonce、请求方法、请求路径（含查询参数）与请求体以换行连接，使用 secretKey 计算 HMAC-SHA256
+func signV2(secretKey, timestamp, nonce, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n", timestamp, nonce, method, requestURI)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignatureV2 验证 v2 签名并返回请求的客户端，请求未携带 X-Signature 时返回 false
+// 签名以固定时间比较，客户端的任一有效密钥签名均可通过；随机串在容差时间内只能使用一次
+func (s *HTTPServer) verifySignatureV2(r *http.Request, body []byte) (*apiClient, bool, error) {
+	signature := strings.TrimSpace(r.Header.Get(HeaderSignature))
+	if signature == "" {
+		return nil, false, nil
+	}
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	clientID := r.Header.Get(HeaderClientID)
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+
+	client := s.clients[clientID]
+	if client == nil {
+		zap.S().Warnf("v2 签名验证失败，客户端不存在: client=%s, path=%s", clientID, r.URL.Path)
+		return nil, true, fmt.Errorf("签名验证失败")
+	}
+	matched := false
+	for _, secret := range client.secrets {
+		expected := signV2(secret, timestamp, nonce, r.Method, r.URL.RequestURI(), body)
+		if hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			matched = true
+		}
+	}
+	if !matched {
+		zap.S().Warnf("v2 签名验证失败: client=%s, path=%s, timestamp=%s, nonce=%s", clientID, r.URL.Path, timestamp, nonce)
+		return nil, true, fmt.Errorf("签名验证失败")
+	}
+
+	if valid, err := s.validateTimestamp(timestamp); !valid {
+		zap.S().Warnf("时间戳验证失败: %v", err)
+		return nil, true, fmt.Errorf("时间戳验证失败: %w", err)
+	}
+
+	if len(nonce) < MinNonceLength || len(nonce) > MaxNonceLength {
+		return nil, true, fmt.Errorf("签名验证失败: nonce 长度应为 %d-%d", MinNonceLength, MaxNonceLength)
+	}
+	if !s.nonces.use(clientID+":"+nonce, time.Now()) {
+		zap.S().Warnf("拒绝重放请求: client=%s, path=%s, nonce=%s", clientID, r.URL.Path, nonce)
+		return nil, true, fmt.Errorf("签名验证失败: nonce 已使用")
+	}
+	return client, true, nil
+}
+
+// legacySignatureEqual 以固定时间比较旧版 MD5 签名（忽略大小写）
+func legacySignatureEqual(expected, signature string) bool {
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
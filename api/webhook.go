@@ -0,0 +1,287 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/notification"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// AlertStatusFiring 告警触发
+	AlertStatusFiring = "firing"
+	// AlertStatusResolved 告警恢复
+	AlertStatusResolved = "resolved"
+)
+
+const (
+	// WebhookAuthBearer Bearer Token 认证
+	WebhookAuthBearer = "bearer"
+	// WebhookAuthBasic Basic 认证
+	WebhookAuthBasic = "basic"
+	// WebhookAuthHeader 自定义请求头令牌认证
+	WebhookAuthHeader = "header"
+	// WebhookAuthNone 不认证，仅用于内网
+	WebhookAuthNone = "none"
+	// DefaultWebhookAuthHeader header 认证默认请求头
+	DefaultWebhookAuthHeader = "X-Auth-Token"
+)
+
+// WebhookResponse 告警 webhook 响应结构
+type WebhookResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message,omitempty"`
+	JobIDs  []string `json:"jobIds,omitempty"` // 提交的任务 ID
+}
+
+// webhookAlert 从各监控系统 webhook 中解析出的告警
+type webhookAlert struct {
+	Name     string            // 告警名称，作为通话播报与企业微信消息中的名称
+	Labels   map[string]string // 用于匹配通知路由的标签
+	Resolved bool              // 是否已恢复
+	StartsAt time.Time
+	EndsAt   time.Time
+	DedupKey string             // 去重键，未设置时以告警名称去重
+	Route    *config.AlertRoute // 直接指定的通知对象，设置时不再匹配路由
+}
+
+// authenticateWebhook 按配置的认证方式验证 webhook 请求
+func authenticateWebhook(r *http.Request, receiver *config.WebhookReceiver) error {
+	method := receiver.Auth
+	if method == "" {
+		switch {
+		case receiver.BearerToken != "":
+			method = WebhookAuthBearer
+		case receiver.Username != "":
+			method = WebhookAuthBasic
+		}
+	}
+
+	switch method {
+	case WebhookAuthBearer:
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if receiver.BearerToken == "" || !ok || !secureEqual(strings.TrimSpace(token), receiver.BearerToken) {
+			return fmt.Errorf("Bearer Token 验证失败")
+		}
+	case WebhookAuthBasic:
+		username, password, ok := r.BasicAuth()
+		if receiver.Username == "" || !ok || !secureEqual(username, receiver.Username) || !secureEqual(password, receiver.Password) {
+			return fmt.Errorf("Basic 认证失败")
+		}
+	case WebhookAuthHeader:
+		header := receiver.Header
+		if header == "" {
+			header = DefaultWebhookAuthHeader
+		}
+		if receiver.Token == "" || !secureEqual(r.Header.Get(header), receiver.Token) {
+			return fmt.Errorf("请求头 %s 验证失败", header)
+		}
+	case WebhookAuthNone:
+	case "":
+		return fmt.Errorf("未配置 webhook 认证")
+	default:
+		return fmt.Errorf("不支持的认证方式: %s", method)
+	}
+	return nil
+}
+
+// secureEqual 以固定时间比较两个字符串
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// matchAlertRoute 返回第一条标签全部匹配的路由，未命中时返回 nil
+func matchAlertRoute(routes []config.AlertRoute, labels map[string]string) *config.AlertRoute {
+	for i := range routes {
+		matched := true
+		for k, v := range routes[i].Match {
+			if labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return &routes[i]
+		}
+	}
+	return nil
+}
+
+// formatLabels 按键名排序输出 key=value 形式的标签，忽略 alertname
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "alertname" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// routeRequest 根据路由生成通知请求
+func routeRequest(name string, route *config.AlertRoute) *NotifyRequest {
+	return &NotifyRequest{
+		Name:         name,
+		PhoneNumbers: strings.Join(route.PhoneNumbers, ","),
+		Mode:         route.Mode,
+		Priority:     route.Priority,
+		Escalation:   route.Escalation,
+		Schedule:     route.Schedule,
+		Contacts:     route.Contacts,
+	}
+}
+
+// dispatchAlert 按路由校验并提交告警的通知任务，与 /api/nofity 使用相同的提交流程，返回任务 ID
+func (s *HTTPServer) dispatchAlert(alert *webhookAlert, routes []config.AlertRoute) (string, error) {
+	route := alert.Route
+	if route == nil {
+		route = matchAlertRoute(routes, alert.Labels)
+	}
+	if route == nil {
+		return "", fmt.Errorf("未匹配通知路由: %s", formatLabels(alert.Labels))
+	}
+
+	req := routeRequest(alert.Name, route)
+	req.DedupKey = alert.DedupKey
+	req.Severity = alert.Labels["severity"]
+	if err := s.validateTargets(req); err != nil {
+		return "", err
+	}
+	result, err := s.enqueueNotify(req)
+	if err != nil {
+		return "", err
+	}
+	return result.job.ID, nil
+}
+
+// sendResolvedNotification 发送告警恢复通知
+func (s *HTTPServer) sendResolvedNotification(alert *webhookAlert) {
+	if s.notify == nil {
+		return
+	}
+
+	endsAt := alert.EndsAt
+	if endsAt.IsZero() {
+		endsAt = time.Now()
+	}
+	message := &notification.Message{
+		Event: notification.EventResolved,
+		Title: "✅ 告警已恢复",
+		Fields: []notification.Field{
+			{Name: "名称", Value: alert.Name},
+			{Name: "标签", Value: formatLabels(alert.Labels)},
+		},
+	}
+	if !alert.StartsAt.IsZero() {
+		message.Fields = append(message.Fields, notification.Field{Name: "开始时间", Value: alert.StartsAt.Local().Format("2006-01-02 15:04:05")})
+	}
+	message.Fields = append(message.Fields, notification.Field{Name: "恢复时间", Value: endsAt.Local().Format("2006-01-02 15:04:05")})
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送告警恢复通知失败: %v", err)
+	}
+}
+
+// sendUndeliverableNotification 发送告警无法提交的提醒，告警未匹配路由或通知对象无效时不会拨打电话
+func (s *HTTPServer) sendUndeliverableNotification(source string, alert *webhookAlert, reason error) {
+	if s.notify == nil {
+		return
+	}
+
+	message := &notification.Message{
+		Event:    notification.EventAlert,
+		Title:    "⚠️ 告警未能提交，未拨打电话",
+		Severity: alert.Labels["severity"],
+		Fields: []notification.Field{
+			{Name: "名称", Value: alert.Name},
+			{Name: "来源", Value: source},
+			{Name: "标签", Value: formatLabels(alert.Labels)},
+			{Name: "原因", Value: reason.Error()},
+			{Name: "时间", Value: time.Now().Format("2006-01-02 15:04:05")},
+		},
+	}
+
+	if err := s.notify.Send(message); err != nil {
+		zap.S().Errorf("发送告警未能提交提醒失败: %v", err)
+	}
+}
+
+// processAlerts 提交触发中告警的通知任务，已恢复的告警只发送恢复通知，返回响应与 HTTP 状态码
+// 任一触发中的告警提交失败时返回失败：限流时状态码为 429，EC600N 未就绪或任务保存失败时为 503，由监控系统重试，
+// 重试时已提交的告警按去重规则忽略；未匹配路由或通知对象无效的告警重试也无法提交，返回 200 并发送未能通知的提醒
+func (s *HTTPServer) processAlerts(source string, alerts []*webhookAlert, routes []config.AlertRoute) (WebhookResponse, int) {
+	response := WebhookResponse{Success: true}
+	statusCode := http.StatusOK
+	var failures []string
+	for _, alert := range alerts {
+		if alert.Resolved {
+			s.sendResolvedNotification(alert)
+			continue
+		}
+
+		id, err := s.dispatchAlert(alert, routes)
+		if err != nil {
+			zap.S().Warnf("%s 告警提交失败 [%s]: %v", source, alert.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", alert.Name, err))
+			switch {
+			case errors.Is(err, errRateLimited):
+				statusCode = http.StatusTooManyRequests
+			case errors.Is(err, errUnavailable):
+				if statusCode == http.StatusOK {
+					statusCode = http.StatusServiceUnavailable
+				}
+			default:
+				s.sendUndeliverableNotification(source, alert, err)
+			}
+			continue
+		}
+		response.JobIDs = append(response.JobIDs, id)
+	}
+	if len(failures) > 0 {
+		response.Success = false
+		response.Message = strings.Join(failures, "; ")
+	}
+	return response, statusCode
+}
+
+// handleWebhook 验证并解析监控系统的 webhook 请求后提交告警
+// parse 将请求体解析为告警列表
+func (s *HTTPServer) handleWebhook(w http.ResponseWriter, r *http.Request, source string, receiver *config.WebhookReceiver, parse func(body *json.Decoder) ([]*webhookAlert, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		s.writeErrorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := authenticateWebhook(r, receiver); err != nil {
+		zap.S().Warnf("%s 认证失败: %v", source, err)
+		s.writeErrorResponse(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	alerts, err := parse(json.NewDecoder(r.Body))
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求体失败: %v", err))
+		return
+	}
+	zap.S().Infof("收到 %s 告警: %d 条", source, len(alerts))
+
+	response, statusCode := s.processAlerts(source, alerts, receiver.Routes)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
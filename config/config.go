@@ -18,14 +18,29 @@ const (
 // Config 应用配置结构
 type Config struct {
 	Wechat struct {
-		WebhookURL string `yaml:"webhook_url"` // 企业微信机器人 webhook 地址
+		WebhookURL  string `yaml:"webhook_url"`  // 企业微信机器人 webhook 地址
+		MsgType     string `yaml:"msg_type"`     // 消息类型：text（默认）、markdown、template_card
+		RateLimit   int    `yaml:"rate_limit"`   // 每个机器人每分钟最多发送消息数，默认 20，同时作用于 notifiers 中的 wecom 渠道
+		MergeWindow int    `yaml:"merge_window"` // 开始发送前等待合并消息的时间（秒），0 表示不等待，限流期间排队的消息仍会合并
 	} `yaml:"wechat"`
+	Notifiers []Notifier `yaml:"notifiers"` // 其他通知渠道，与企业微信同时发送
+	Outbox    struct {
+		MaxAttempts     int      `yaml:"max_attempts"`     // 每个渠道最多发送次数（含首次发送），默认 5，之后改为短信通知
+		InitialBackoff  int      `yaml:"initial_backoff"`  // 首次重试间隔（秒），之后每次翻倍并加入随机抖动，默认 10
+		MaxBackoff      int      `yaml:"max_backoff"`      // 最大重试间隔（秒），默认 600
+		FallbackNumbers []string `yaml:"fallback_numbers"` // 通知多次发送失败后通过 EC600N 发送短信的号码，为空时不发送
+		FallbackEvents  []string `yaml:"fallback_events"`  // 需要短信兜底的事件类型，默认 alert、escalation、ack、resolved、limit
+	} `yaml:"outbox"`
 	EC600N struct {
 		Enabled              bool   `yaml:"enabled"`                // 是否启用 EC600N 功能
 		SerialPort           string `yaml:"serial_port"`            // 串口设备路径
 		BaudRate             int    `yaml:"baud_rate"`              // 波特率
 		HTTPPort             int    `yaml:"http_port"`              // HTTP 服务端口
 		CallDuration         int    `yaml:"call_duration"`          // 通话时长（秒）
+		RingTimeout          int    `yaml:"ring_timeout"`           // 振铃超时（秒），超时未接听视为无人接听
+		SMSMode              string `yaml:"sms_mode"`               // 短信发送模式：pdu（默认，支持中文长短信）或 text
+		DTMFConfirm          bool   `yaml:"dtmf_confirm"`           // 是否要求接听人在通话中按键确认
+		DTMFKey              string `yaml:"dtmf_key"`               // 确认按键，默认 1
 		NetworkCheckInterval int    `yaml:"network_check_interval"` // 网络状态检查间隔（分钟）
 		CheckInterval        int    `yaml:"check_interval"`         // 检查间隔（分钟）
 	} `yaml:"ec600n"`
@@ -37,9 +52,184 @@ type Config struct {
 		MaxBackups int    `yaml:"maxBackups"`
 	} `yaml:"logger"`
 	API struct {
-		SecretKey string `yaml:"secret_key"` // API签名密钥
-		HTTPPort  int    `yaml:"http_port"`  // HTTP服务端口
+		SecretKey       string      `yaml:"secret_key"`       // API签名密钥
+		HTTPPort        int         `yaml:"http_port"`        // HTTP服务端口
+		LegacySignature bool        `yaml:"legacy_signature"` // 是否允许旧版 MD5 签名（无重放保护，仅 call、sms、status 权限），默认只接受 v2 HMAC-SHA256 签名
+		Clients         []APIClient `yaml:"clients"`          // 调用方客户端，使用 v2 签名并通过 X-Client-ID 请求头标识
+		JobURL          string      `yaml:"job_url"`          // 任务状态页面地址，{id} 替换为任务 ID，通知消息中附带该链接
 	} `yaml:"api"`
+	Limits struct {
+		NumberCallsPerHour int `yaml:"number_calls_per_hour"` // 每个号码每小时最多拨打次数，0 不限制
+		CallsPerHour       int `yaml:"calls_per_hour"`        // 全局每小时最多拨打次数，0 不限制
+		AlertCooldown      int `yaml:"alert_cooldown"`        // 同名告警的冷却时间（分钟），冷却期内的重复告警被拒绝，0 不限制
+	} `yaml:"limits"`
+	Dedup struct {
+		Window      int `yaml:"window"`       // 去重窗口（分钟），窗口内相同告警名称或 dedupKey 的重复告警被忽略，0 只对显式 dedupKey 的未结束任务去重
+		GroupWindow int `yaml:"group_window"` // 分组窗口（秒），窗口内通知对象相同的告警合并为一次拨号，0 不分组
+	} `yaml:"dedup"`
+	Ack struct {
+		Keywords []string `yaml:"keywords"` // 确认短信关键字（忽略大小写），默认 ACK、1
+	} `yaml:"ack"`
+	Escalation struct {
+		Policies map[string]EscalationPolicy `yaml:"policies"` // 升级策略，键为策略名称
+	} `yaml:"escalation"`
+	Schedules map[string]Schedule `yaml:"schedules"` // 值班表，键为值班表名称
+	Contacts  struct {
+		Timezone string                   `yaml:"timezone"` // 免打扰时段使用的时区，默认 Asia/Shanghai
+		People   map[string]ContactPerson `yaml:"people"`   // 联系人，键为联系人 ID
+		Teams    map[string]ContactTeam   `yaml:"teams"`    // 团队，键为团队 ID，请求中以 team:<ID> 引用
+	} `yaml:"contacts"`
+	TTS struct {
+		Enabled   bool   `yaml:"enabled"`    // 是否在接通后播报告警内容
+		Template  string `yaml:"template"`   // 播报模板，{name} 替换为告警名称
+		Repeat    int    `yaml:"repeat"`     // 播报次数，默认 1 次
+		AudioFile string `yaml:"audio_file"` // 模块中的音频文件，设置后播放该文件代替 TTS
+	} `yaml:"tts"`
+	Alertmanager WebhookReceiver `yaml:"alertmanager"` // Prometheus Alertmanager webhook 接收配置
+	Grafana      WebhookReceiver `yaml:"grafana"`      // Grafana 统一告警 webhook 接收配置
+	Zabbix       struct {
+		WebhookReceiver `yaml:",inline"`
+		Fields          ZabbixFields `yaml:"fields"`          // 告警字段映射
+		ResolvedValues  []string     `yaml:"resolved_values"` // 状态字段为这些值时视为恢复（忽略大小写），默认 RESOLVED、OK、0
+	} `yaml:"zabbix"`
+	Hooks   map[string]Hook `yaml:"hooks"` // 通用 webhook，键为名称，请求地址为 /api/hooks/<名称>
+	Storage struct {
+		DataDir string `yaml:"data_dir"` // 本地数据目录（短信收件箱、任务队列等），默认 data
+	} `yaml:"storage"`
+}
+
+// EscalationPolicy 告警升级策略
+// 按顺序通知各级联系人，每级通知后等待确认，超时未确认则通知下一级；整条链路可重复多轮
+type EscalationPolicy struct {
+	Tiers  []EscalationTier `yaml:"tiers"`  // 升级层级
+	Repeat int              `yaml:"repeat"` // 整条链路执行轮数，默认 1 轮
+}
+
+// EscalationTier 升级层级
+type EscalationTier struct {
+	Numbers  []string `yaml:"numbers"`  // 本级通知号码，依次拨打
+	Schedule string   `yaml:"schedule"` // 本级值班表，通知时拨打当前值班人员
+	Contacts []string `yaml:"contacts"` // 本级联系人或团队（team:<ID>）
+	Wait     int      `yaml:"wait"`     // 本级通知后等待确认的时间（分钟），超时后通知下一级
+}
+
+// Schedule 值班表
+// 从 Start 日期的交接时间开始，成员按顺序轮流值班，每班 ShiftDays 天；Overrides 用于节假日、换班等临时调整
+type Schedule struct {
+	Timezone    string             `yaml:"timezone"`     // 时区，默认 Asia/Shanghai
+	Start       string             `yaml:"start"`        // 轮换起始日期（2006-01-02），第一位成员从该日交接时间开始值班
+	ShiftDays   int                `yaml:"shift_days"`   // 每班天数，默认 7（每周轮换）
+	HandoffTime string             `yaml:"handoff_time"` // 交接时间（15:04），默认 09:00
+	Members     []ScheduleMember   `yaml:"members"`      // 轮换成员，按顺序值班
+	Overrides   []ScheduleOverride `yaml:"overrides"`    // 临时调整，优先于轮换
+}
+
+// ScheduleMember 值班成员
+type ScheduleMember struct {
+	Name  string `yaml:"name"`
+	Phone string `yaml:"phone"`
+}
+
+// ScheduleOverride 值班临时调整，在 [Start, End) 期间由指定人员值班
+type ScheduleOverride struct {
+	Start string `yaml:"start"` // 开始时间（2006-01-02 15:04）
+	End   string `yaml:"end"`   // 结束时间（2006-01-02 15:04）
+	Name  string `yaml:"name"`  // 值班人员，为成员名称时可省略 phone
+	Phone string `yaml:"phone"`
+}
+
+// ContactPerson 联系人
+type ContactPerson struct {
+	Name         string   `yaml:"name"`           // 姓名
+	Phones       []string `yaml:"phones"`         // 电话号码，按顺序拨打
+	WechatUserID string   `yaml:"wechat_user_id"` // 企业微信用户 ID，用于消息中 @ 提醒
+	QuietHours   string   `yaml:"quiet_hours"`    // 免打扰时段（如 23:00-07:00），期间不拨打电话
+}
+
+// ContactTeam 团队
+type ContactTeam struct {
+	Name    string   `yaml:"name"`    // 团队名称
+	Members []string `yaml:"members"` // 成员联系人 ID
+}
+
+// APIClient API 调用方客户端
+type APIClient struct {
+	ID             string   `yaml:"id"`              // 客户端 ID，出现在日志与通知消息中
+	Secrets        []string `yaml:"secrets"`         // 签名密钥，轮换时可同时配置新旧两个密钥
+	Scopes         []string `yaml:"scopes"`          // 权限：call、sms、status、admin
+	AllowedNumbers []string `yaml:"allowed_numbers"` // 允许通知的号码，与 allowed_teams 均为空时不限制
+	AllowedTeams   []string `yaml:"allowed_teams"`   // 允许通知的团队（含团队成员）
+	RateLimit      int      `yaml:"rate_limit"`      // 每分钟最多请求数，0 不限制
+}
+
+// WebhookReceiver 告警 webhook 接收配置
+type WebhookReceiver struct {
+	Auth        string       `yaml:"auth"`         // 认证方式：bearer、basic、header、none，未配置时按 bearer_token、username 推断
+	BearerToken string       `yaml:"bearer_token"` // Bearer Token 认证
+	Username    string       `yaml:"username"`     // Basic 认证用户名
+	Password    string       `yaml:"password"`     // Basic 认证密码
+	Header      string       `yaml:"header"`       // header 认证的请求头名称，默认 X-Auth-Token
+	Token       string       `yaml:"token"`        // header 认证的令牌
+	Routes      []AlertRoute `yaml:"routes"`       // 按标签匹配的通知路由，按顺序匹配第一条
+}
+
+// Hook 通用 webhook 配置
+// 表达式以 $ 开头时按 JSONPath 从请求体中取值，包含 {{ 时按 Go 模板渲染（数据为请求体），否则为固定值
+type Hook struct {
+	WebhookReceiver `yaml:",inline"`
+	Name            string            `yaml:"name"`            // 告警名称表达式
+	Severity        string            `yaml:"severity"`        // 告警级别表达式，作为 severity 标签参与路由匹配
+	Contacts        string            `yaml:"contacts"`        // 通知联系人表达式，结果为联系人 ID 或 team:<ID> 列表（数组或逗号分隔）
+	PhoneNumbers    string            `yaml:"phone_numbers"`   // 通知号码表达式，结果为号码列表（数组或逗号分隔）
+	DedupKey        string            `yaml:"dedup_key"`       // 去重键表达式，默认以告警名称去重
+	Status          string            `yaml:"status"`          // 告警状态表达式
+	ResolvedValues  []string          `yaml:"resolved_values"` // 状态为这些值时只发送恢复通知（忽略大小写），默认 resolved、ok
+	Labels          map[string]string `yaml:"labels"`          // 额外的路由标签，值为表达式
+	Mode            string            `yaml:"mode"`            // 按 contacts、phone_numbers 通知时的通知方式
+	Priority        int               `yaml:"priority"`        // 按 contacts、phone_numbers 通知时的任务优先级
+}
+
+// Notifier 通知渠道
+type Notifier struct {
+	Name       string              `yaml:"name"`        // 渠道名称，用于日志，默认为类型
+	Type       string              `yaml:"type"`        // 渠道类型：wecom、dingtalk、feishu、slack、telegram、webhook、email
+	WebhookURL string              `yaml:"webhook_url"` // 机器人 webhook 地址；telegram 为 Bot API 地址，默认 https://api.telegram.org
+	Secret     string              `yaml:"secret"`      // 钉钉、飞书机器人加签密钥，为空时不签名
+	BotToken   string              `yaml:"bot_token"`   // telegram 机器人 token
+	ChatID     string              `yaml:"chat_id"`     // telegram 会话 ID
+	Headers    map[string]string   `yaml:"headers"`     // 通用 webhook 附加请求头
+	MsgType    string              `yaml:"msg_type"`    // wecom 消息类型，同 wechat.msg_type
+	SMTP       SMTP                `yaml:"smtp"`        // email 渠道的 SMTP 服务器
+	Recipients map[string][]string `yaml:"recipients"`  // email 渠道按事件类型配置的收件人，default 用于未单独配置的事件
+}
+
+// SMTP 邮件服务器
+type SMTP struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`     // 端口，默认 starttls 为 587、tls 为 465、none 为 25
+	Security string `yaml:"security"` // 加密方式：starttls（默认）、tls（隐式 TLS）、none
+	Username string `yaml:"username"` // 认证用户名，为空时不认证
+	Password string `yaml:"password"`
+	From     string `yaml:"from"` // 发件人地址，默认为 username
+}
+
+// ZabbixFields Zabbix 媒介类型 webhook 的字段映射，值为请求 JSON 中的字段名
+type ZabbixFields struct {
+	Name     string `yaml:"name"`     // 告警标题字段，默认 subject
+	Status   string `yaml:"status"`   // 告警状态字段，默认 status
+	Severity string `yaml:"severity"` // 告警级别字段，默认 severity
+}
+
+// AlertRoute 告警通知路由
+// Match 中的标签全部相等时命中，Match 为空的路由匹配所有告警；通知对象的含义与 /api/nofity 请求字段相同
+type AlertRoute struct {
+	Match        map[string]string `yaml:"match"`         // 标签匹配条件，如 severity: critical、team: dba
+	PhoneNumbers []string          `yaml:"phone_numbers"` // 通知号码
+	Contacts     []string          `yaml:"contacts"`      // 联系人或团队（team:<ID>）
+	Schedule     string            `yaml:"schedule"`      // 值班表名称
+	Escalation   string            `yaml:"escalation"`    // 升级策略名称
+	Mode         string            `yaml:"mode"`          // 通知方式：call（默认）、sms_call、sms
+	Priority     int               `yaml:"priority"`      // 任务优先级
 }
 
 // LoadConfig 从 YAML 文件加载配置
@@ -0,0 +1,269 @@
+package contact
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// 内置时区数据，运行环境缺少 tzdata 时也能加载免打扰时区
+	_ "time/tzdata"
+
+	"alert-mobile-notify/config"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTimezone 免打扰时段默认时区
+	DefaultTimezone = "Asia/Shanghai"
+	// TeamPrefix 请求中引用团队的前缀，如 team:dba
+	TeamPrefix = "team:"
+
+	clockLayout = "15:04"
+)
+
+// quietHours 每日免打扰时段，end 早于 start 时表示跨越零点
+type quietHours struct {
+	start, end time.Duration
+}
+
+// contains 判断一天中的时刻是否在免打扰时段内
+func (q *quietHours) contains(t time.Time) bool {
+	clock := clockOf(t)
+	if q.start <= q.end {
+		return clock >= q.start && clock < q.end
+	}
+	return clock >= q.start || clock < q.end
+}
+
+// parseQuietHours 解析 HH:MM-HH:MM 格式的免打扰时段
+func parseQuietHours(s string) (*quietHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("格式应为 HH:MM-HH:MM")
+	}
+	start, err := time.Parse(clockLayout, strings.TrimSpace(from))
+	if err != nil {
+		return nil, fmt.Errorf("开始时间格式错误 [%s]", from)
+	}
+	end, err := time.Parse(clockLayout, strings.TrimSpace(to))
+	if err != nil {
+		return nil, fmt.Errorf("结束时间格式错误 [%s]", to)
+	}
+	return &quietHours{start: clockOf(start), end: clockOf(end)}, nil
+}
+
+// clockOf 返回一天中从零点起的时长（精确到分钟）
+func clockOf(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// Person 联系人
+type Person struct {
+	ID           string
+	Name         string
+	Phones       []string
+	WechatUserID string
+	quiet        *quietHours
+}
+
+// Quiet 判断 t 时刻是否处于联系人的免打扰时段
+func (p *Person) Quiet(t time.Time) bool {
+	return p.quiet != nil && p.quiet.contains(t)
+}
+
+// Recipient 解析后的通知对象
+type Recipient struct {
+	Contact      string `json:"contact"` // 联系人 ID
+	Name         string `json:"name"`
+	Phone        string `json:"phone"`
+	WechatUserID string `json:"wechatUserId,omitempty"`
+}
+
+// Directory 联系人通讯录
+type Directory struct {
+	loc    *time.Location
+	people map[string]*Person
+	teams  map[string][]string
+	phones map[string]*Person // 规范化号码到联系人的索引
+}
+
+// NewDirectory 根据配置创建通讯录，团队成员必须是已配置的联系人
+func NewDirectory(cfg *config.Config) (*Directory, error) {
+	tz := cfg.Contacts.Timezone
+	if tz == "" {
+		tz = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("通讯录时区无效 [%s]: %w", tz, err)
+	}
+
+	d := &Directory{
+		loc:    loc,
+		people: make(map[string]*Person, len(cfg.Contacts.People)),
+		teams:  make(map[string][]string, len(cfg.Contacts.Teams)),
+		phones: make(map[string]*Person),
+	}
+
+	for id, c := range cfg.Contacts.People {
+		p := &Person{ID: id, Name: c.Name, WechatUserID: c.WechatUserID}
+		if p.Name == "" {
+			p.Name = id
+		}
+		for _, phone := range c.Phones {
+			if phone = strings.TrimSpace(phone); phone != "" {
+				p.Phones = append(p.Phones, phone)
+				d.phones[CanonicalPhone(phone)] = p
+			}
+		}
+		if len(p.Phones) == 0 {
+			return nil, fmt.Errorf("联系人 %s 未配置电话", id)
+		}
+		if c.QuietHours != "" {
+			if p.quiet, err = parseQuietHours(c.QuietHours); err != nil {
+				return nil, fmt.Errorf("联系人 %s 免打扰时段无效: %w", id, err)
+			}
+		}
+		d.people[id] = p
+	}
+
+	for id, t := range cfg.Contacts.Teams {
+		for _, member := range t.Members {
+			if d.people[member] == nil {
+				return nil, fmt.Errorf("团队 %s 的成员不存在: %s", id, member)
+			}
+		}
+		d.teams[id] = t.Members
+	}
+	return d, nil
+}
+
+// Validate 检查联系人与团队引用是否存在
+func (d *Directory) Validate(refs []string) error {
+	_, err := d.expand(refs)
+	return err
+}
+
+// Resolve 将联系人与团队引用解析为通知对象，同一联系人只出现一次
+// 处于免打扰时段的联系人会被跳过；若全部联系人都处于免打扰时段，则仍然通知全部联系人，避免告警无人接收
+func (d *Directory) Resolve(refs []string, at time.Time) ([]Recipient, error) {
+	people, err := d.expand(refs)
+	if err != nil || len(people) == 0 {
+		return nil, err
+	}
+
+	local := at.In(d.loc)
+	var active []*Person
+	for _, p := range people {
+		if p.Quiet(local) {
+			zap.S().Infof("联系人 %s 处于免打扰时段，跳过", p.ID)
+			continue
+		}
+		active = append(active, p)
+	}
+	if len(active) == 0 {
+		zap.S().Warnf("联系人均处于免打扰时段，仍然通知全部联系人: %v", refs)
+		active = people
+	}
+
+	var recipients []Recipient
+	for _, p := range active {
+		for _, phone := range p.Phones {
+			recipients = append(recipients, Recipient{
+				Contact:      p.ID,
+				Name:         p.Name,
+				Phone:        phone,
+				WechatUserID: p.WechatUserID,
+			})
+		}
+	}
+	return recipients, nil
+}
+
+// IsMember 判断联系人是否为团队成员
+func (d *Directory) IsMember(team, id string) bool {
+	members, _ := d.team(team)
+	for _, member := range members {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupPhone 按号码查找联系人，未找到时返回 nil
+func (d *Directory) LookupPhone(phone string) *Person {
+	if d == nil {
+		return nil
+	}
+	return d.phones[CanonicalPhone(phone)]
+}
+
+// expand 展开团队引用，按引用顺序返回去重后的联系人
+func (d *Directory) expand(refs []string) ([]*Person, error) {
+	seen := make(map[string]bool)
+	var people []*Person
+	add := func(id string) error {
+		if d == nil || d.people[id] == nil {
+			return fmt.Errorf("联系人不存在: %s", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			people = append(people, d.people[id])
+		}
+		return nil
+	}
+
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		if team, ok := strings.CutPrefix(ref, TeamPrefix); ok {
+			members, exists := d.team(team)
+			if !exists {
+				return nil, fmt.Errorf("团队不存在: %s", team)
+			}
+			for _, member := range members {
+				if err := add(member); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := add(ref); err != nil {
+			return nil, err
+		}
+	}
+	return people, nil
+}
+
+// team 返回团队成员
+func (d *Directory) team(id string) ([]string, bool) {
+	if d == nil {
+		return nil, false
+	}
+	members, ok := d.teams[id]
+	return members, ok
+}
+
+// CanonicalPhone 只保留数字并去除中国国家码前缀，用于号码比较
+func CanonicalPhone(phone string) string {
+	var sb strings.Builder
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			sb.WriteRune(c)
+		}
+	}
+	digits := sb.String()
+	if len(digits) == 13 && strings.HasPrefix(digits, "86") {
+		digits = digits[2:]
+	}
+	return digits
+}
+
+// ProvideDirectory 提供通讯录依赖注入
+func ProvideDirectory() fx.Option {
+	return fx.Provide(NewDirectory)
+}
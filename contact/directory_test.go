@@ -0,0 +1,91 @@
+package contact
+
+import (
+	"testing"
+	"time"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDirectory(t *testing.T) *Directory {
+	cfg := &config.Config{}
+	cfg.Contacts.People = map[string]config.ContactPerson{
+		"zhangsan": {Name: "张三", Phones: []string{"13800138000", "010-12345678"}, WechatUserID: "zs", QuietHours: "23:00-07:00"},
+		"lisi":     {Name: "李四", Phones: []string{"13900139000"}, QuietHours: "12:00-13:30"},
+		"wangwu":   {Phones: []string{"+86 137 0013 7000"}},
+	}
+	cfg.Contacts.Teams = map[string]config.ContactTeam{
+		"dba": {Members: []string{"zhangsan", "lisi"}},
+	}
+	d, err := NewDirectory(cfg)
+	require.NoError(t, err)
+	return d
+}
+
+func TestDirectory_Resolve(t *testing.T) {
+	d := newTestDirectory(t)
+	loc, _ := time.LoadLocation(DefaultTimezone)
+	noon := time.Date(2026, 1, 5, 10, 0, 0, 0, loc)
+
+	recipients, err := d.Resolve([]string{"team:dba", "zhangsan", "wangwu"}, noon)
+	require.NoError(t, err)
+	var phones []string
+	for _, r := range recipients {
+		phones = append(phones, r.Phone)
+	}
+	assert.Equal(t, []string{"13800138000", "010-12345678", "13900139000", "+86 137 0013 7000"}, phones)
+	assert.Equal(t, "张三", recipients[0].Name)
+	assert.Equal(t, "zs", recipients[0].WechatUserID)
+	assert.Equal(t, "wangwu", recipients[3].Name)
+
+	// 免打扰时段跨越零点
+	night := time.Date(2026, 1, 5, 23, 30, 0, 0, loc)
+	recipients, err = d.Resolve([]string{"team:dba"}, night)
+	require.NoError(t, err)
+	require.Len(t, recipients, 1)
+	assert.Equal(t, "lisi", recipients[0].Contact)
+
+	// 全部处于免打扰时段时仍然通知
+	recipients, err = d.Resolve([]string{"zhangsan"}, night.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, recipients, 2)
+
+	_, err = d.Resolve([]string{"team:ops"}, noon)
+	assert.Error(t, err)
+	assert.Error(t, d.Validate([]string{"nobody"}))
+	assert.NoError(t, d.Validate(nil))
+
+	assert.Equal(t, "wangwu", d.LookupPhone("13700137000").ID)
+	assert.Nil(t, d.LookupPhone("13600136000"))
+	var empty *Directory
+	assert.Nil(t, empty.LookupPhone("13800138000"))
+	assert.Error(t, empty.Validate([]string{"zhangsan"}))
+}
+
+func TestNewDirectory_Invalid(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Contacts.People = map[string]config.ContactPerson{"zhangsan": {Phones: []string{"13800138000"}}}
+	cfg.Contacts.Teams = map[string]config.ContactTeam{"dba": {Members: []string{"lisi"}}}
+	_, err := NewDirectory(cfg)
+	assert.Error(t, err)
+
+	cfg.Contacts.Teams = nil
+	cfg.Contacts.People["lisi"] = config.ContactPerson{Phones: []string{"13900139000"}, QuietHours: "23:00"}
+	_, err = NewDirectory(cfg)
+	assert.Error(t, err)
+
+	cfg.Contacts.People["lisi"] = config.ContactPerson{}
+	_, err = NewDirectory(cfg)
+	assert.Error(t, err)
+}
+
+func TestNewDirectory_ExampleConfig(t *testing.T) {
+	cfg, err := config.LoadConfig("../config.yaml")
+	require.NoError(t, err)
+
+	_, err = NewDirectory(cfg)
+	assert.NoError(t, err)
+}
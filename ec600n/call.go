@@ -0,0 +1,379 @@
+package ec600n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRingTimeout  = 45 * time.Second // 默认振铃超时时间
+	DefaultCallDuration = 10 * time.Second // 默认接通后通话时长
+	CallPollInterval    = time.Second      // AT+CLCC 轮询间隔
+	HangupTimeout       = 5 * time.Second  // 挂断指令超时时间
+)
+
+// reCLCC 解析 +CLCC: <id>,<dir>,<stat>,<mode>,<mpty>[,<number>,<type>]
+var reCLCC = regexp.MustCompile(`\+CLCC:\s*(\d+),(\d),(\d),(\d),(\d)(?:,"([^"]*)")?`)
+
+// CallState 通话状态
+type CallState string
+
+const (
+	CallStateDialing  CallState = "dialing"  // 拨号中
+	CallStateAlerting CallState = "alerting" // 对方振铃中
+	CallStateActive   CallState = "active"   // 已接通
+	CallStateEnded    CallState = "ended"    // 已结束
+)
+
+// CallEndReason 通话结束原因
+type CallEndReason string
+
+const (
+	EndReasonRemoteHangup CallEndReason = "remote_hangup" // 接通后对方挂断
+	EndReasonLocalHangup  CallEndReason = "local_hangup"  // 达到通话时长后本端挂断
+	EndReasonNoAnswer     CallEndReason = "no_answer"     // 振铃超时无人接听
+	EndReasonBusy         CallEndReason = "busy"          // 对方忙
+	EndReasonRejected     CallEndReason = "rejected"      // 振铃期间被拒接
+	EndReasonFailed       CallEndReason = "failed"        // 拨号失败或未能建立呼叫
+	EndReasonCancelled    CallEndReason = "cancelled"     // 呼叫被取消
+	EndReasonConfirmed    CallEndReason = "confirmed"     // 对方按键确认后挂断
+)
+
+// endReasonText 通话结束原因描述
+var endReasonText = map[CallEndReason]string{
+	EndReasonRemoteHangup: "对方挂断",
+	EndReasonLocalHangup:  "到达通话时长后挂断",
+	EndReasonNoAnswer:     "无人接听",
+	EndReasonBusy:         "对方忙",
+	EndReasonRejected:     "被拒接",
+	EndReasonFailed:       "拨号失败",
+	EndReasonCancelled:    "已取消",
+	EndReasonConfirmed:    "已按键确认",
+}
+
+// CallOptions 呼叫参数
+type CallOptions struct {
+	MaxDuration  time.Duration // 接通后最长通话时长，到达后本端挂断
+	RingTimeout  time.Duration // 振铃最长等待时间，超时视为无人接听
+	PollInterval time.Duration // AT+CLCC 轮询间隔
+	ConfirmDigit string        // 确认按键，非空时接通后开启 DTMF 检测，对方按下该键即确认并挂断
+
+	Announcement   string // 接通后 TTS 播报的内容
+	AnnounceFile   string // 接通后播放的模块音频文件，设置时优先于 Announcement
+	AnnounceRepeat int    // 播报次数，默认 1 次
+}
+
+// CallResult 呼叫结果
+type CallResult struct {
+	Number       string        `json:"number"`
+	State        CallState     `json:"state"`
+	EndReason    CallEndReason `json:"end_reason,omitempty"`
+	Detail       string        `json:"detail,omitempty"` // 失败时的模块响应或错误信息
+	StartedAt    time.Time     `json:"started_at"`
+	AlertingAt   time.Time     `json:"alerting_at,omitempty"`
+	AnsweredAt   time.Time     `json:"answered_at,omitempty"`
+	EndedAt      time.Time     `json:"ended_at,omitempty"`
+	RingDuration time.Duration `json:"ring_duration"` // 振铃时长
+	TalkDuration time.Duration `json:"talk_duration"` // 通话时长
+	Confirmed    bool          `json:"confirmed"`     // 对方是否按键确认
+	ConfirmedAt  time.Time     `json:"confirmed_at,omitempty"`
+}
+
+// Answered 是否已接通
+func (r *CallResult) Answered() bool {
+	return !r.AnsweredAt.IsZero()
+}
+
+// Summary 返回呼叫结果的简要描述
+func (r *CallResult) Summary() string {
+	reason := endReasonText[r.EndReason]
+	if reason == "" {
+		reason = string(r.EndReason)
+	}
+	if r.Answered() && r.Confirmed {
+		return fmt.Sprintf("%s: 已接通并按键确认，振铃 %s，通话 %s",
+			r.Number, r.RingDuration.Round(time.Second), r.TalkDuration.Round(time.Second))
+	}
+	if r.Answered() {
+		return fmt.Sprintf("%s: 已接通，振铃 %s，通话 %s，%s",
+			r.Number, r.RingDuration.Round(time.Second), r.TalkDuration.Round(time.Second), reason)
+	}
+	if r.Detail != "" {
+		return fmt.Sprintf("%s: 未接通，%s - %s", r.Number, reason, r.Detail)
+	}
+	return fmt.Sprintf("%s: 未接通，振铃 %s，%s", r.Number, r.RingDuration.Round(time.Second), reason)
+}
+
+// clccEntry AT+CLCC 返回的单个呼叫
+type clccEntry struct {
+	outgoing bool
+	stat     string
+	voice    bool
+	number   string
+}
+
+// Call 拨打电话并跟踪呼叫结果
+// 通过轮询 AT+CLCC 与监听 NO CARRIER/BUSY/NO ANSWER 判断呼叫状态，
+// 对方挂断后立即返回；接通超过 MaxDuration 或振铃超过 RingTimeout 时本端挂断；
+// 设置 ConfirmDigit 时，对方在通话中按下确认键即标记为已确认并挂断；
+// 设置 Announcement 或 AnnounceFile 时，接通后播报告警内容
+func (e *EC600N) Call(ctx context.Context, phoneNumber string, opts CallOptions) (*CallResult, error) {
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultCallDuration
+	}
+	if opts.RingTimeout <= 0 {
+		opts.RingTimeout = DefaultRingTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = CallPollInterval
+	}
+
+	phoneNumber = normalizePhoneNumber(phoneNumber)
+	result := &CallResult{
+		Number:    phoneNumber,
+		State:     CallStateDialing,
+		StartedAt: time.Now(),
+	}
+	if phoneNumber == "" {
+		return e.endCall(result, EndReasonFailed, "电话号码不能为空"), fmt.Errorf("电话号码不能为空")
+	}
+
+	// 拨号前订阅，避免遗漏通话结束事件
+	events, unsubscribe := e.Subscribe(EventNoCarrier, EventBusy, EventNoAnswer, EventDTMF)
+	defer unsubscribe()
+
+	dtmfEnabled := false
+	defer func() {
+		if dtmfEnabled {
+			e.setToneDetection(false)
+		}
+	}()
+
+	// 播报在接通后异步进行，呼叫结束时停止播报并等待其退出
+	announceCtx, stopAnnounce := context.WithCancel(ctx)
+	announceDone := make(chan struct{})
+	announcing := false
+	defer func() {
+		stopAnnounce()
+		if announcing {
+			<-announceDone
+		}
+	}()
+
+	zap.S().Infof("开始拨打电话: %s", phoneNumber)
+	dial, err := e.Exec(ctx, fmt.Sprintf("ATD%s;", phoneNumber), DialTimeout)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			e.hangupQuietly()
+			return e.endCall(result, EndReasonCancelled, err.Error()), err
+		}
+		e.hangupQuietly()
+		return e.endCall(result, EndReasonFailed, err.Error()), fmt.Errorf("发送拨号指令失败: %w", err)
+	}
+	switch {
+	case dial.OK() || strings.HasPrefix(dial.Final, "CONNECT"):
+	case dial.Final == "BUSY":
+		return e.endCall(result, EndReasonBusy, ""), nil
+	case dial.Final == "NO ANSWER":
+		return e.endCall(result, EndReasonNoAnswer, ""), nil
+	default:
+		return e.endCall(result, EndReasonFailed, dial.Text()), fmt.Errorf("拨打电话失败，响应: %s", dial.Text())
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.hangupQuietly()
+			return e.endCall(result, EndReasonCancelled, ""), ctx.Err()
+
+		case ev := <-events:
+			switch ev.Type {
+			case EventDTMF:
+				if result.State != CallStateActive || opts.ConfirmDigit == "" {
+					continue
+				}
+				if ev.Digit != opts.ConfirmDigit {
+					zap.S().Infof("收到非确认按键 [%s]: %s", phoneNumber, ev.Digit)
+					continue
+				}
+				result.Confirmed = true
+				result.ConfirmedAt = ev.Time
+				zap.S().Infof("对方已按键确认: %s", phoneNumber)
+				e.hangupQuietly()
+				return e.endCall(result, EndReasonConfirmed, ""), nil
+			case EventBusy:
+				return e.endCall(result, EndReasonBusy, ""), nil
+			case EventNoAnswer:
+				return e.endCall(result, EndReasonNoAnswer, ""), nil
+			default:
+				return e.endCall(result, e.endReasonByState(result.State), ""), nil
+			}
+
+		case <-ticker.C:
+			entry, err := e.currentOutgoingCall(ctx)
+			if err != nil {
+				zap.S().Warnf("查询通话状态失败 [%s]: %v", phoneNumber, err)
+			} else if entry == nil {
+				// 呼叫已不存在，说明对方已挂断或呼叫失败
+				return e.endCall(result, e.endReasonByState(result.State), ""), nil
+			} else {
+				wasActive := result.State == CallStateActive
+				e.updateCallState(result, entry.stat)
+				if !wasActive && result.State == CallStateActive {
+					if opts.ConfirmDigit != "" {
+						dtmfEnabled = e.setToneDetection(true)
+					}
+					if opts.Announcement != "" || opts.AnnounceFile != "" {
+						announcing = true
+						go func() {
+							defer close(announceDone)
+							e.announce(announceCtx, opts)
+						}()
+					}
+				}
+			}
+
+			switch result.State {
+			case CallStateActive:
+				if time.Since(result.AnsweredAt) >= opts.MaxDuration {
+					e.hangupQuietly()
+					return e.endCall(result, EndReasonLocalHangup, ""), nil
+				}
+			default:
+				if time.Since(result.StartedAt) >= opts.RingTimeout {
+					e.hangupQuietly()
+					return e.endCall(result, EndReasonNoAnswer, ""), nil
+				}
+			}
+		}
+	}
+}
+
+// currentOutgoingCall 通过 AT+CLCC 查询当前主叫语音呼叫，不存在时返回 nil
+func (e *EC600N) currentOutgoingCall(ctx context.Context) (*clccEntry, error) {
+	result, err := e.Exec(ctx, "AT+CLCC", DefaultATTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := result.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, line := range result.Lines {
+		m := reCLCC.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		entry := &clccEntry{
+			outgoing: m[2] == "0",
+			stat:     m[3],
+			voice:    m[4] == "0",
+			number:   m[6],
+		}
+		if entry.outgoing && entry.voice {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// updateCallState 根据 +CLCC 的 stat 更新呼叫状态
+// stat: 0 通话中, 1 保持, 2 拨号中, 3 振铃中
+func (e *EC600N) updateCallState(result *CallResult, stat string) {
+	switch stat {
+	case "2":
+		result.State = CallStateDialing
+	case "3":
+		if result.State != CallStateAlerting {
+			result.State = CallStateAlerting
+			result.AlertingAt = time.Now()
+			zap.S().Infof("对方振铃中: %s", result.Number)
+		}
+	case "0", "1":
+		if result.State != CallStateActive {
+			result.State = CallStateActive
+			result.AnsweredAt = time.Now()
+			zap.S().Infof("电话已接通: %s", result.Number)
+		}
+	}
+}
+
+// endReasonByState 呼叫在远端结束时，根据结束前的状态推断原因
+func (e *EC600N) endReasonByState(state CallState) CallEndReason {
+	switch state {
+	case CallStateActive:
+		return EndReasonRemoteHangup
+	case CallStateAlerting:
+		return EndReasonRejected
+	default:
+		return EndReasonFailed
+	}
+}
+
+// endCall 结束呼叫并计算振铃、通话时长
+func (e *EC600N) endCall(result *CallResult, reason CallEndReason, detail string) *CallResult {
+	result.State = CallStateEnded
+	result.EndReason = reason
+	result.Detail = detail
+	result.EndedAt = time.Now()
+
+	ringStart := result.AlertingAt
+	if ringStart.IsZero() {
+		ringStart = result.StartedAt
+	}
+	if result.Answered() {
+		result.RingDuration = result.AnsweredAt.Sub(ringStart)
+		result.TalkDuration = result.EndedAt.Sub(result.AnsweredAt)
+	} else {
+		result.RingDuration = result.EndedAt.Sub(ringStart)
+	}
+
+	zap.S().Infof("呼叫结束: %s", result.Summary())
+	return result
+}
+
+// setToneDetection 开启或关闭通话中的 DTMF 检测（AT+QTONEDET），返回是否执行成功
+func (e *EC600N) setToneDetection(enable bool) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultATTimeout)
+	defer cancel()
+
+	command := "AT+QTONEDET=0"
+	if enable {
+		command = "AT+QTONEDET=1"
+	}
+	if err := e.execOK(ctx, command); err != nil {
+		zap.S().Errorf("设置 DTMF 检测失败 [%s]: %v", command, err)
+		return false
+	}
+	return true
+}
+
+// hangupQuietly 挂断电话，失败时仅记录日志
+func (e *EC600N) hangupQuietly() {
+	ctx, cancel := context.WithTimeout(context.Background(), HangupTimeout)
+	defer cancel()
+
+	result, err := e.Exec(ctx, "ATH", HangupTimeout)
+	if err == nil {
+		err = result.Err()
+	}
+	if err != nil {
+		zap.S().Errorf("挂断电话失败: %v", err)
+	}
+}
+
+// normalizePhoneNumber 清理电话号码，移除空格和连字符
+func normalizePhoneNumber(phoneNumber string) string {
+	phoneNumber = strings.TrimSpace(phoneNumber)
+	phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
+	return strings.ReplaceAll(phoneNumber, " ", "")
+}
@@ -3,10 +3,15 @@ package ec600n
 import (
 	"alert-mobile-notify/config"
 	"alert-mobile-notify/notification"
-	"bufio"
+	"alert-mobile-notify/storage"
+	"context"
 	"fmt"
+	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tarm/serial"
@@ -15,10 +20,8 @@ import (
 )
 
 const (
-	ATCommandTimeout  = 100 * time.Millisecond // AT 指令响应超时时间
-	MaxResponseLines  = 10                     // 最大响应行数
-	MinSignalStrength = 5                      // 最小正常信号强度
-	IMEILength        = 15                     // IMEI 标准长度
+	MinSignalStrength = 5  // 最小正常信号强度
+	IMEILength        = 15 // IMEI 标准长度
 )
 
 var (
@@ -51,108 +54,156 @@ type NetworkStatus struct {
 // EC600N EC600N模块控制器
 type EC600N struct {
 	config    *config.Config
-	port      *serial.Port
+	exec      *atExecutor
+	events    *EventBus
 	connected bool
 
-	notify *notification.WechatNotify
+	smsMu  sync.Mutex    // 短信指令序列互斥
+	smsRef atomic.Uint32 // 级联短信参考号
+	inbox  *SMSInbox     // 短信收件箱
+
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	notify notification.Notifier
 }
 
 // NewEC600N 创建新的 EC600N 实例
 // 如果配置中未启用 EC600N 功能，返回 nil
-func NewEC600N(cfg *config.Config, notify *notification.WechatNotify) (*EC600N, error) {
+func NewEC600N(cfg *config.Config, notify notification.Notifier) (*EC600N, error) {
 	if !cfg.EC600N.Enabled {
 		return nil, nil
 	}
 
+	inboxFile, err := storage.NewJSONFile(storage.DataDir(cfg), InboxFileName)
+	if err != nil {
+		return nil, err
+	}
+	inbox, err := NewSMSInbox(inboxFile)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := openSerial(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("初始化串口失败: %w", err)
+	}
+
+	ec, err := newEC600N(cfg, notify, port, inbox)
+	if err != nil {
+		return nil, err
+	}
+
+	zap.S().Info("EC600N 模块初始化成功")
+	return ec, nil
+}
+
+// newEC600N 基于已打开的串口创建 EC600N 实例，启动 AT 指令执行器与短信接收处理
+func newEC600N(cfg *config.Config, notify notification.Notifier, port io.ReadWriteCloser, inbox *SMSInbox) (*EC600N, error) {
+	events := NewEventBus()
 	ec := &EC600N{
 		config:    cfg,
+		exec:      newATExecutor(port, events),
+		events:    events,
 		connected: false,
 		notify:    notify,
-	}
-
-	if err := ec.initSerial(); err != nil {
-		return nil, fmt.Errorf("初始化串口失败: %w", err)
+		inbox:     inbox,
+		stop:      make(chan struct{}),
 	}
 
 	if err := ec.testConnection(); err != nil {
+		ec.exec.Close()
 		return nil, fmt.Errorf("测试连接失败: %w", err)
 	}
 
+	ec.enableURC()
+	// 固定字符集，避免沿用上次运行遗留的 UCS2 设置
+	if err := ec.resetCharset(context.Background()); err != nil {
+		zap.S().Warnf("设置 GSM 字符集失败: %v", err)
+	}
+
+	smsEvents, _ := ec.Subscribe(EventNewSMS)
+	go ec.runInboundSMS(smsEvents)
+
 	ec.connected = true
-	zap.S().Info("EC600N 模块初始化成功")
 	return ec, nil
 }
 
-// initSerial 初始化串口连接
-func (e *EC600N) initSerial() error {
+// openSerial 打开串口连接
+func openSerial(cfg *config.Config) (*serial.Port, error) {
 	c := &serial.Config{
-		Name: e.config.EC600N.SerialPort,
-		Baud: e.config.EC600N.BaudRate,
+		Name:        cfg.EC600N.SerialPort,
+		Baud:        cfg.EC600N.BaudRate,
+		ReadTimeout: SerialReadTimeout,
 	}
 
 	port, err := serial.OpenPort(c)
 	if err != nil {
-		return fmt.Errorf("打开串口失败 [%s]: %w", e.config.EC600N.SerialPort, err)
+		return nil, fmt.Errorf("打开串口失败 [%s]: %w", cfg.EC600N.SerialPort, err)
 	}
 
-	e.port = port
-	return nil
+	return port, nil
 }
 
 // testConnection 测试连接
 func (e *EC600N) testConnection() error {
 	// 发送AT指令测试连接
-	response, err := e.sendATCommand("AT")
+	result, err := e.sendATCommand("AT")
 	if err != nil {
 		return err
 	}
 
-	if !strings.Contains(response, "OK") {
-		return fmt.Errorf("AT指令测试失败，响应: %s", response)
+	if !result.OK() {
+		return fmt.Errorf("AT指令测试失败，响应: %s", result.Text())
 	}
 
 	return nil
 }
 
-// sendATCommand 发送 AT 指令并读取响应
-func (e *EC600N) sendATCommand(command string) (string, error) {
-	if e.port == nil {
-		return "", fmt.Errorf("串口未连接")
-	}
-
-	if err := e.port.Flush(); err != nil {
-		return "", fmt.Errorf("清空串口缓冲区失败: %w", err)
-	}
-
-	fullCommand := command + "\r\n"
-	if _, err := e.port.Write([]byte(fullCommand)); err != nil {
-		return "", fmt.Errorf("发送 AT 指令失败: %w", err)
+// enableURC 开启模块主动上报（来电号码、网络注册状态变化、新短信 +CMTI）
+// 部分固件不支持时仅记录告警，不影响模块使用
+func (e *EC600N) enableURC() {
+	for _, command := range []string{"AT+CLIP=1", "AT+CREG=1", "AT+CNMI=2,1,0,0,0"} {
+		result, err := e.sendATCommand(command)
+		if err == nil {
+			err = result.Err()
+		}
+		if err != nil {
+			zap.S().Warnf("开启主动上报失败 [%s]: %v", command, err)
+		}
 	}
+}
 
-	time.Sleep(ATCommandTimeout)
-
-	return e.readResponse()
+// Events 返回模块事件总线
+func (e *EC600N) Events() *EventBus {
+	return e.events
 }
 
-// readResponse 读取串口响应
-func (e *EC600N) readResponse() (string, error) {
-	reader := bufio.NewReader(e.port)
-	var response strings.Builder
+// Subscribe 订阅模块主动上报事件，types 为空表示订阅全部事件
+func (e *EC600N) Subscribe(types ...EventType) (<-chan Event, func()) {
+	return e.events.Subscribe(types...)
+}
 
-	for i := 0; i < MaxResponseLines; i++ {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		response.WriteString(line)
+// Exec 通过 AT 指令执行器发送指令，支持超时与 ctx 取消
+// 所有串口访问都必须经由此方法，保证同一时刻只有一条指令在执行
+func (e *EC600N) Exec(ctx context.Context, command string, timeout time.Duration) (*ATResult, error) {
+	if e.exec == nil {
+		return nil, ErrNotConnected
+	}
+	return e.exec.Exec(ctx, command, timeout)
+}
 
-		// 响应结束标志
-		if strings.Contains(line, "OK") || strings.Contains(line, "ERROR") {
-			break
-		}
+// ExecWithData 发送需要二次输入数据的 AT 指令（如 AT+CMGS）
+func (e *EC600N) ExecWithData(ctx context.Context, command, data string, timeout time.Duration) (*ATResult, error) {
+	if e.exec == nil {
+		return nil, ErrNotConnected
 	}
+	return e.exec.ExecWithData(ctx, command, data, timeout)
+}
 
-	return response.String(), nil
+// sendATCommand 以默认超时发送 AT 指令
+func (e *EC600N) sendATCommand(command string) (*ATResult, error) {
+	return e.Exec(context.Background(), command, DefaultATTimeout)
 }
 
 // CheckNetworkStatus 检查网络状态
@@ -173,10 +224,11 @@ func (e *EC600N) CheckNetworkStatus() (*NetworkStatus, error) {
 
 // getSignalStrength 获取信号强度 (0-31, 99表示未知)
 func (e *EC600N) getSignalStrength() (int, error) {
-	response, err := e.sendATCommand("AT+CSQ")
+	result, err := e.sendATCommand("AT+CSQ")
 	if err != nil {
 		return 0, fmt.Errorf("发送 AT+CSQ 指令失败: %w", err)
 	}
+	response := result.Text()
 
 	matches := reCSQ.FindStringSubmatch(response)
 	if len(matches) < 3 {
@@ -193,10 +245,11 @@ func (e *EC600N) getSignalStrength() (int, error) {
 
 // getNetworkRegistrationStatus 获取网络注册状态
 func (e *EC600N) getNetworkRegistrationStatus() (string, error) {
-	response, err := e.sendATCommand("AT+CREG?")
+	result, err := e.sendATCommand("AT+CREG?")
 	if err != nil {
 		return "", fmt.Errorf("发送 AT+CREG 指令失败: %w", err)
 	}
+	response := result.Text()
 
 	matches := reCREG.FindStringSubmatch(response)
 	if len(matches) < 2 {
@@ -213,10 +266,11 @@ func (e *EC600N) getNetworkRegistrationStatus() (string, error) {
 
 // getSIMStatus 获取SIM卡状态
 func (e *EC600N) getSIMStatus() (string, error) {
-	response, err := e.sendATCommand("AT+CPIN?")
+	result, err := e.sendATCommand("AT+CPIN?")
 	if err != nil {
 		return "", err
 	}
+	response := result.Text()
 
 	switch {
 	case strings.Contains(response, "READY"):
@@ -232,10 +286,11 @@ func (e *EC600N) getSIMStatus() (string, error) {
 
 // getOperatorName 获取运营商名称
 func (e *EC600N) getOperatorName() (string, error) {
-	response, err := e.sendATCommand("AT+COPS?")
+	result, err := e.sendATCommand("AT+COPS?")
 	if err != nil {
 		return "", err
 	}
+	response := result.Text()
 
 	matches := reCOPS.FindStringSubmatch(response)
 	if len(matches) < 2 {
@@ -247,34 +302,32 @@ func (e *EC600N) getOperatorName() (string, error) {
 
 // getIMEI 获取设备 IMEI
 func (e *EC600N) getIMEI() (string, error) {
-	response, err := e.sendATCommand("AT+CGSN")
+	result, err := e.sendATCommand("AT+CGSN")
 	if err != nil {
 		return "", fmt.Errorf("发送 AT+CGSN 指令失败: %w", err)
 	}
 
 	// 解析 IMEI，去除空白字符
-	lines := strings.Split(response, "\n")
-	for _, line := range lines {
+	for _, line := range result.Lines {
 		line = strings.TrimSpace(line)
 		if len(line) == IMEILength && reIMEI.MatchString(line) {
 			return line, nil
 		}
 	}
 
-	return "", fmt.Errorf("无法解析 IMEI，响应: %s", response)
+	return "", fmt.Errorf("无法解析 IMEI，响应: %s", result.Text())
 }
 
 // MakeCall 拨打电话
 // 使用 ATD 指令拨打电话号码
 func (e *EC600N) MakeCall(phoneNumber string) error {
-	if e.port == nil {
-		return fmt.Errorf("串口未连接")
-	}
+	return e.MakeCallContext(context.Background(), phoneNumber)
+}
 
+// MakeCallContext 拨打电话，支持通过 ctx 取消排队中的拨号指令
+func (e *EC600N) MakeCallContext(ctx context.Context, phoneNumber string) error {
 	// 清理电话号码，移除空格和特殊字符
-	phoneNumber = strings.TrimSpace(phoneNumber)
-	phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-	phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
+	phoneNumber = normalizePhoneNumber(phoneNumber)
 
 	if phoneNumber == "" {
 		return fmt.Errorf("电话号码不能为空")
@@ -282,29 +335,29 @@ func (e *EC600N) MakeCall(phoneNumber string) error {
 
 	// 发送拨号指令 ATD<number>;
 	command := fmt.Sprintf("ATD%s;", phoneNumber)
-	response, err := e.sendATCommand(command)
+	result, err := e.Exec(ctx, command, DialTimeout)
 	if err != nil {
 		return fmt.Errorf("发送拨号指令失败: %w", err)
 	}
 
 	// 检查响应
-	if strings.Contains(response, "OK") || strings.Contains(response, "CONNECT") {
+	if result.OK() || strings.HasPrefix(result.Final, "CONNECT") {
 		zap.S().Infof("拨打电话成功: %s", phoneNumber)
 		return nil
 	}
 
-	return fmt.Errorf("拨打电话失败，响应: %s", response)
+	return fmt.Errorf("拨打电话失败，响应: %s", result.Text())
 }
 
 // HangupCall 挂断电话
 func (e *EC600N) HangupCall() error {
-	response, err := e.sendATCommand("ATH")
+	result, err := e.sendATCommand("ATH")
 	if err != nil {
 		return fmt.Errorf("挂断电话失败: %w", err)
 	}
 
-	if !strings.Contains(response, "OK") {
-		return fmt.Errorf("挂断电话失败，响应: %s", response)
+	if !result.OK() {
+		return fmt.Errorf("挂断电话失败，响应: %s", result.Text())
 	}
 
 	return nil
@@ -313,8 +366,9 @@ func (e *EC600N) HangupCall() error {
 // Close 关闭连接
 func (e *EC600N) Close() error {
 	e.connected = false
-	if e.port != nil {
-		return e.port.Close()
+	e.closeOnce.Do(func() { close(e.stop) })
+	if e.exec != nil {
+		return e.exec.Close()
 	}
 	return nil
 }
@@ -329,9 +383,15 @@ func (e *EC600N) StartNetworkMonitoring() error {
 	status, err := e.CheckNetworkStatus()
 	if err != nil {
 		zap.S().Errorf("检查网络状态失败: %v", err)
-		message := fmt.Sprintf("EC600N 网络检查失败: %v\n时间: %s",
-			err, time.Now().Format("2006-01-02 15:04:05"))
-		if notifyErr := e.notify.SendToWechat(message); notifyErr != nil {
+		message := &notification.Message{
+			Event: notification.EventNetwork,
+			Title: "EC600N 网络检查失败",
+			Fields: []notification.Field{
+				{Name: "错误", Value: err.Error()},
+				{Name: "时间", Value: time.Now().Format("2006-01-02 15:04:05")},
+			},
+		}
+		if notifyErr := e.notify.Send(message); notifyErr != nil {
 			zap.S().Errorf("发送网络异常通知失败: %v", notifyErr)
 		}
 		return fmt.Errorf("检查网络状态失败: %w", err)
@@ -342,10 +402,13 @@ func (e *EC600N) StartNetworkMonitoring() error {
 		statusText = "异常"
 	}
 
-	message := fmt.Sprintf("EC600N 网络状态报告\n状态: %s\n%s",
-		statusText, e.formatNetworkStatus(status))
+	message := &notification.Message{
+		Event:  notification.EventNetwork,
+		Title:  "EC600N 网络状态报告",
+		Fields: append([]notification.Field{{Name: "状态", Value: statusText}}, e.networkStatusFields(status)...),
+	}
 
-	if err := e.notify.SendToWechat(message); err != nil {
+	if err := e.notify.Send(message); err != nil {
 		zap.S().Errorf("发送网络状态报告失败: %v", err)
 		return fmt.Errorf("发送网络状态报告失败: %w", err)
 	}
@@ -361,23 +424,50 @@ func (e *EC600N) isNetworkStatusNormal(status *NetworkStatus) bool {
 		status.SIMStatus == "就绪"
 }
 
-// formatNetworkStatus 格式化网络状态信息
-func (e *EC600N) formatNetworkStatus(status *NetworkStatus) string {
-	return fmt.Sprintf(`信号强度: %d
-网络注册状态: %s
-SIM卡状态: %s
-运营商: %s
-IMEI: %s
-时间: %s`,
-		status.SignalStrength,
-		status.NetworkRegStatus,
-		status.SIMStatus,
-		status.OperatorName,
-		status.IMEI,
-		status.Timestamp.Format("2006-01-02 15:04:05"))
+// networkStatusFields 返回网络状态信息的通知字段
+func (e *EC600N) networkStatusFields(status *NetworkStatus) []notification.Field {
+	return []notification.Field{
+		{Name: "信号强度", Value: strconv.Itoa(status.SignalStrength)},
+		{Name: "网络注册状态", Value: status.NetworkRegStatus},
+		{Name: "SIM卡状态", Value: status.SIMStatus},
+		{Name: "运营商", Value: status.OperatorName},
+		{Name: "IMEI", Value: status.IMEI},
+		{Name: "时间", Value: status.Timestamp.Format("2006-01-02 15:04:05")},
+	}
 }
 
 // ProvideEC600N 提供EC600N依赖注入
 func ProvideEC600N() fx.Option {
-	return fx.Provide(NewEC600N)
+	return fx.Options(
+		fx.Provide(NewEC600N),
+		fx.Invoke(registerEC600NLifecycle, registerSMSFallback),
+	)
+}
+
+// registerSMSFallback 将 EC600N 短信注册为通知多次发送失败后的兜底方式
+func registerSMSFallback(registry *notification.Registry, ec *EC600N) {
+	if ec == nil || registry.Outbox() == nil {
+		return
+	}
+	registry.Outbox().SetSMSFallback(func(phoneNumber, text string) error {
+		_, err := ec.SendSMS(phoneNumber, text)
+		return err
+	})
+}
+
+// registerEC600NLifecycle 注册 EC600N 生命周期，停止时关闭 AT 指令执行器与串口
+func registerEC600NLifecycle(lifecycle fx.Lifecycle, ec *EC600N) {
+	if ec == nil {
+		return
+	}
+
+	lifecycle.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			if err := ec.Close(); err != nil {
+				return fmt.Errorf("关闭 EC600N 串口失败: %w", err)
+			}
+			zap.S().Info("EC600N 串口已关闭")
+			return nil
+		},
+	})
 }
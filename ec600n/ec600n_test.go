@@ -0,0 +1,537 @@
+package ec600n
+
+import (
+	"alert-mobile-notify/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModem 模拟 EC600N 串口，按指令前缀返回预设响应
+type fakeModem struct {
+	mu       sync.Mutex
+	handlers map[string]func(cmd string) string
+	commands []string
+
+	r   *io.PipeReader
+	w   *io.PipeWriter
+	out chan string
+}
+
+func newFakeModem() *fakeModem {
+	r, w := io.Pipe()
+	m := &fakeModem{
+		handlers: make(map[string]func(cmd string) string),
+		r:        r,
+		w:        w,
+		out:      make(chan string, 64),
+	}
+	go func() {
+		for s := range m.out {
+			if _, err := m.w.Write([]byte(s)); err != nil {
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// Handle 注册指令处理函数，按最长前缀匹配；返回空字符串表示不响应
+func (m *fakeModem) Handle(prefix string, fn func(cmd string) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[prefix] = fn
+}
+
+// Emit 主动输出数据（模拟 URC）
+func (m *fakeModem) Emit(s string) {
+	m.out <- s
+}
+
+// Commands 返回已收到的指令
+func (m *fakeModem) Commands() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.commands...)
+}
+
+func (m *fakeModem) Read(p []byte) (int, error) {
+	return m.r.Read(p)
+}
+
+func (m *fakeModem) Write(p []byte) (int, error) {
+	cmd := strings.TrimSpace(string(p))
+
+	m.mu.Lock()
+	m.commands = append(m.commands, cmd)
+	var handler func(string) string
+	matched := -1
+	for prefix, fn := range m.handlers {
+		if strings.HasPrefix(cmd, prefix) && len(prefix) > matched {
+			handler, matched = fn, len(prefix)
+		}
+	}
+	m.mu.Unlock()
+
+	resp := "\r\nOK\r\n"
+	if handler != nil {
+		resp = handler(cmd)
+	}
+	if resp != "" {
+		m.Emit(resp)
+	}
+	return len(p), nil
+}
+
+func (m *fakeModem) Close() error {
+	m.r.Close()
+	return m.w.Close()
+}
+
+func newTestEC600N(t *testing.T, modem *fakeModem) *EC600N {
+	t.Helper()
+	inbox, err := NewSMSInbox(nil)
+	require.NoError(t, err)
+	ec, err := newEC600N(&config.Config{}, nil, modem, inbox)
+	require.NoError(t, err)
+	t.Cleanup(func() { ec.Close() })
+	return ec
+}
+
+// TestExec_Serialized 测试并发指令串行执行且响应不串扰
+func TestExec_Serialized(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+TEST=", func(cmd string) string {
+		n := strings.TrimPrefix(cmd, "AT+TEST=")
+		return fmt.Sprintf("%s\r\n+TEST: %s\r\n\r\nOK\r\n", cmd, n)
+	})
+	ec := newTestEC600N(t, modem)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := ec.Exec(context.Background(), fmt.Sprintf("AT+TEST=%d", i), time.Second)
+			if assert.NoError(t, err) {
+				assert.True(t, result.OK())
+				assert.Equal(t, []string{fmt.Sprintf("+TEST: %d", i)}, result.Lines)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestExec_Timeout 测试指令超时后执行器仍可继续工作
+func TestExec_Timeout(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+SILENT", func(string) string { return "" })
+	ec := newTestEC600N(t, modem)
+
+	_, err := ec.Exec(context.Background(), "AT+SILENT", 100*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrATTimeout))
+
+	result, err := ec.Exec(context.Background(), "AT", time.Second)
+	require.NoError(t, err)
+	assert.True(t, result.OK())
+}
+
+// TestExec_LateFinalCode 测试超时指令迟到的结果码不会被当作下一条指令的响应
+func TestExec_LateFinalCode(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("ATD", func(string) string {
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			modem.Emit("\r\nOK\r\n")
+		}()
+		return ""
+	})
+	// 下一条指令的响应在迟到的结果码之后到达
+	modem.Handle("AT+CSQ", func(string) string {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			modem.Emit("\r\n+CSQ: 20,99\r\n\r\nOK\r\n")
+		}()
+		return ""
+	})
+	ec := newTestEC600N(t, modem)
+
+	_, err := ec.Exec(context.Background(), "ATD13800138000;", 100*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrATTimeout))
+
+	result, err := ec.Exec(context.Background(), "AT+CSQ", time.Second)
+	require.NoError(t, err)
+	assert.True(t, result.OK())
+	assert.Equal(t, []string{"+CSQ: 20,99"}, result.Lines)
+}
+
+// TestExec_ContextCancel 测试 ctx 取消
+func TestExec_ContextCancel(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+SILENT", func(string) string { return "" })
+	ec := newTestEC600N(t, modem)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := ec.Exec(ctx, "AT+SILENT", 10*time.Second)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+// TestCheckNetworkStatus 测试网络状态解析
+func TestCheckNetworkStatus(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+CSQ", func(string) string { return "+CSQ: 24,99\r\nOK\r\n" })
+	modem.Handle("AT+CREG?", func(string) string { return "+CREG: 0,1\r\nOK\r\n" })
+	modem.Handle("AT+CPIN?", func(string) string { return "+CPIN: READY\r\nOK\r\n" })
+	modem.Handle("AT+COPS?", func(string) string { return "+COPS: 0,0,\"CHINA MOBILE\",7\r\nOK\r\n" })
+	modem.Handle("AT+CGSN", func(string) string { return "861234567890123\r\nOK\r\n" })
+	ec := newTestEC600N(t, modem)
+
+	status, err := ec.CheckNetworkStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 24, status.SignalStrength)
+	assert.Equal(t, "已注册本地网络", status.NetworkRegStatus)
+	assert.Equal(t, "就绪", status.SIMStatus)
+	assert.Equal(t, "CHINA MOBILE", status.OperatorName)
+	assert.Equal(t, "861234567890123", status.IMEI)
+	assert.True(t, ec.isNetworkStatusNormal(status))
+}
+
+// TestURC_Idle 测试空闲时收到的 URC 被发布为事件
+func TestURC_Idle(t *testing.T) {
+	modem := newFakeModem()
+	ec := newTestEC600N(t, modem)
+
+	events, unsubscribe := ec.Subscribe(EventRing, EventCallerID, EventNewSMS)
+	defer unsubscribe()
+
+	modem.Emit("\r\nRING\r\n\r\n+CLIP: \"13800138000\",129,,,,0\r\n+CMTI: \"SM\",3\r\n")
+
+	ev := waitEvent(t, events)
+	assert.Equal(t, EventRing, ev.Type)
+	ev = waitEvent(t, events)
+	assert.Equal(t, EventCallerID, ev.Type)
+	assert.Equal(t, "13800138000", ev.Number)
+	ev = waitEvent(t, events)
+	assert.Equal(t, EventNewSMS, ev.Type)
+	assert.Equal(t, "SM", ev.Storage)
+	assert.Equal(t, 3, ev.Index)
+}
+
+// TestURC_DuringCommand 测试指令执行期间的 URC 不混入响应
+func TestURC_DuringCommand(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+CSQ", func(string) string {
+		return "+CREG: 5\r\nNO CARRIER\r\n+CSQ: 20,99\r\nOK\r\n"
+	})
+	modem.Handle("AT+CREG?", func(string) string { return "+CREG: 1,1\r\nOK\r\n" })
+	ec := newTestEC600N(t, modem)
+
+	events, unsubscribe := ec.Subscribe()
+	defer unsubscribe()
+
+	result, err := ec.Exec(context.Background(), "AT+CSQ", time.Second)
+	require.NoError(t, err)
+	assert.True(t, result.OK())
+	assert.Equal(t, []string{"+CSQ: 20,99"}, result.Lines)
+
+	ev := waitEvent(t, events)
+	assert.Equal(t, EventNetworkReg, ev.Type)
+	assert.Equal(t, "5", ev.Status)
+	ev = waitEvent(t, events)
+	assert.Equal(t, EventNoCarrier, ev.Type)
+
+	// 查询指令的同名响应不应被当作 URC
+	result, err = ec.Exec(context.Background(), "AT+CREG?", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+CREG: 1,1"}, result.Lines)
+}
+
+func waitEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("等待事件超时")
+		return Event{}
+	}
+}
+
+// TestCall_RemoteHangup 测试接通后对方挂断时提前结束
+func TestCall_RemoteHangup(t *testing.T) {
+	modem := newFakeModem()
+	var polls int
+	modem.Handle("AT+CLCC", func(string) string {
+		polls++
+		switch {
+		case polls <= 2:
+			return "+CLCC: 1,0,3,0,0,\"13800138000\",129\r\nOK\r\n"
+		case polls <= 4:
+			return "+CLCC: 1,0,0,0,0,\"13800138000\",129\r\nOK\r\n"
+		default:
+			modem.Emit("NO CARRIER\r\n")
+			return "OK\r\n"
+		}
+	})
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.Call(context.Background(), "138-0013-8000", CallOptions{
+		MaxDuration:  time.Minute,
+		PollInterval: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", result.Number)
+	assert.Equal(t, CallStateEnded, result.State)
+	assert.Equal(t, EndReasonRemoteHangup, result.EndReason)
+	assert.True(t, result.Answered())
+	assert.False(t, result.AlertingAt.IsZero())
+	assert.NotContains(t, modem.Commands(), "ATH")
+}
+
+// TestCall_DTMFConfirm 测试通话中按下确认键
+func TestCall_DTMFConfirm(t *testing.T) {
+	modem := newFakeModem()
+	var polls int
+	modem.Handle("AT+CLCC", func(string) string {
+		polls++
+		if polls == 3 {
+			modem.Emit("+QTONEDET: 49\r\n")
+		}
+		return "+CLCC: 1,0,0,0,0,\"13800138000\",129\r\nOK\r\n"
+	})
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.Call(context.Background(), "13800138000", CallOptions{
+		MaxDuration:  time.Minute,
+		PollInterval: 20 * time.Millisecond,
+		ConfirmDigit: "1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, EndReasonConfirmed, result.EndReason)
+	assert.True(t, result.Confirmed)
+	assert.False(t, result.ConfirmedAt.IsZero())
+	assert.Contains(t, modem.Commands(), "AT+QTONEDET=1")
+	assert.Contains(t, modem.Commands(), "ATH")
+}
+
+func TestCall_Announcement(t *testing.T) {
+	modem := newFakeModem()
+	var polls int
+	modem.Handle("AT+CLCC", func(string) string {
+		polls++
+		if polls > 40 {
+			modem.Emit("NO CARRIER\r\n")
+			return "OK\r\n"
+		}
+		return "+CLCC: 1,0,0,0,0,\"13800138000\",129\r\nOK\r\n"
+	})
+	modem.Handle("AT+QTTS=2", func(string) string {
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			modem.Emit("+QTTS: 0\r\n")
+		}()
+		return "OK\r\n"
+	})
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.Call(context.Background(), "13800138000", CallOptions{
+		MaxDuration:    time.Minute,
+		PollInterval:   20 * time.Millisecond,
+		Announcement:   "告警",
+		AnnounceRepeat: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, EndReasonRemoteHangup, result.EndReason)
+
+	var played int
+	for _, cmd := range modem.Commands() {
+		if strings.HasPrefix(cmd, "AT+QTTS=2") {
+			assert.Equal(t, `AT+QTTS=2,"544A8B66"`, cmd)
+			played++
+		}
+	}
+	assert.Equal(t, 2, played)
+}
+
+// TestSpeak_WaitsForCompletion 测试播报只在播放结束上报后返回，忽略播放开始上报
+func TestSpeak_WaitsForCompletion(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+QTTS=2", func(string) string {
+		go func() {
+			modem.Emit("+QTTS: 1\r\n")
+			time.Sleep(100 * time.Millisecond)
+			modem.Emit("+QTTS: 0\r\n")
+		}()
+		return "OK\r\n"
+	})
+	ec := newTestEC600N(t, modem)
+
+	start := time.Now()
+	require.NoError(t, ec.Speak(context.Background(), "告警"))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+// TestCall_Busy 测试对方忙
+func TestCall_Busy(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("ATD", func(string) string { return "BUSY\r\n" })
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.Call(context.Background(), "13800138000", CallOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, EndReasonBusy, result.EndReason)
+	assert.False(t, result.Answered())
+}
+
+// TestCall_NoAnswer 测试振铃超时后本端挂断
+func TestCall_NoAnswer(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+CLCC", func(string) string {
+		return "+CLCC: 1,0,3,0,0,\"13800138000\",129\r\nOK\r\n"
+	})
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.Call(context.Background(), "13800138000", CallOptions{
+		RingTimeout:  100 * time.Millisecond,
+		PollInterval: 20 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, EndReasonNoAnswer, result.EndReason)
+	assert.Contains(t, modem.Commands(), "ATH")
+}
+
+// TestBuildSubmitPDUs 测试 SMS-SUBMIT PDU 编码
+func TestBuildSubmitPDUs(t *testing.T) {
+	assert.Len(t, gsm7Alphabet, 128)
+
+	pdus, encoding, err := buildSubmitPDUs("13800138000", "hello", 1)
+	require.NoError(t, err)
+	assert.Equal(t, SMSEncodingGSM7, encoding)
+	require.Len(t, pdus, 1)
+	assert.Equal(t, "0011000B813108108300F00000AA05E8329BFD06", pdus[0].Hex)
+	assert.Equal(t, 19, pdus[0].TPDULen)
+
+	pdus, encoding, err = buildSubmitPDUs("+8613800138000", "你好", 1)
+	require.NoError(t, err)
+	assert.Equal(t, SMSEncodingUCS2, encoding)
+	assert.Equal(t, "0011000D91683108108300F00008AA044F60597D", pdus[0].Hex)
+
+	// 超过 70 个中文字符拆分为级联短信
+	pdus, _, err = buildSubmitPDUs("13800138000", strings.Repeat("告", 150), 7)
+	require.NoError(t, err)
+	require.Len(t, pdus, 3)
+	for i, pdu := range pdus {
+		assert.True(t, strings.HasPrefix(pdu.Hex, "0051"), "需设置 UDHI 标志")
+		assert.Contains(t, pdu.Hex, fmt.Sprintf("050003070%d0%d", 3, i+1))
+	}
+}
+
+// TestSendSMS 测试通过 AT+CMGS 发送级联短信
+func TestSendSMS(t *testing.T) {
+	modem := newFakeModem()
+	var ref int
+	modem.Handle("AT+CMGS=", func(string) string { return "\r\n> " })
+	modem.Handle("00", func(cmd string) string {
+		if !strings.HasSuffix(cmd, ctrlZ) {
+			return "ERROR\r\n"
+		}
+		ref++
+		return fmt.Sprintf("\r\n+CMGS: %d\r\n\r\nOK\r\n", ref)
+	})
+	ec := newTestEC600N(t, modem)
+
+	result, err := ec.SendSMS("13800138000", strings.Repeat("数据库主库宕机", 20))
+	require.NoError(t, err)
+	assert.Equal(t, SMSModePDU, result.Mode)
+	assert.Equal(t, SMSEncodingUCS2, result.Encoding)
+	assert.Equal(t, 3, result.Parts)
+	assert.Equal(t, []int{1, 2, 3}, result.References)
+	assert.Contains(t, modem.Commands(), "AT+CMGF=0")
+}
+
+// TestSendTextSMS_ResetCharset 测试文本模式发送中文短信后恢复 GSM 字符集
+func TestSendTextSMS_ResetCharset(t *testing.T) {
+	modem := newFakeModem()
+	modem.Handle("AT+CMGS=", func(string) string { return "\r\n> " })
+	modem.Handle("6570", func(string) string { return "\r\n+CMGS: 1\r\n\r\nOK\r\n" })
+	ec := newTestEC600N(t, modem)
+	ec.config.EC600N.SMSMode = SMSModeText
+	assert.Contains(t, modem.Commands(), `AT+CSCS="GSM"`)
+
+	result, err := ec.SendSMS("13800138000", "数据库主库宕机")
+	require.NoError(t, err)
+	assert.Equal(t, SMSEncodingUCS2, result.Encoding)
+
+	commands := modem.Commands()
+	assert.Contains(t, commands, `AT+CSCS="UCS2"`)
+	assert.Equal(t, `AT+CSCS="GSM"`, commands[len(commands)-1])
+}
+
+// TestDecodeDeliverPDU 测试 SMS-DELIVER PDU 解析
+func TestDecodeDeliverPDU(t *testing.T) {
+	part, err := decodeDeliverPDU("07911326040000F0040B911346610089F60000208062917314080CC8F71D14969741F977FD07")
+	require.NoError(t, err)
+	assert.Equal(t, "+31641600986", part.Sender)
+	assert.Equal(t, SMSEncodingGSM7, part.Encoding)
+	assert.Equal(t, "How are you?", part.Text)
+	assert.Equal(t, 2002, part.SentAt.Year())
+
+	part, err = decodeDeliverPDU("0891683108200105F0040B813108108300F0000862106151400023044F60597D")
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", part.Sender)
+	assert.Equal(t, "你好", part.Text)
+	assert.Equal(t, 0, part.Total)
+	_, offset := part.SentAt.Zone()
+	assert.Equal(t, 8*3600, offset)
+
+	// 带 UDH 的级联短信分段
+	part, err = decodeDeliverPDU("0891683108200105F0440B813108108300F000086210615140002308050003" + "0A02014F60")
+	require.NoError(t, err)
+	assert.Equal(t, "你", part.Text)
+	assert.Equal(t, 10, part.Ref)
+	assert.Equal(t, 2, part.Total)
+	assert.Equal(t, 1, part.Seq)
+}
+
+// TestInboundSMS 测试收到 +CMTI 后读取、合并分段、发布事件并删除短信
+func TestInboundSMS(t *testing.T) {
+	modem := newFakeModem()
+	pdus := map[string]string{
+		"AT+CMGR=1": "0891683108200105F0440B813108108300F0000862106151400023080500030A02014F60",
+		"AT+CMGR=2": "0891683108200105F0440B813108108300F0000862106151400023080500030A0202597D",
+	}
+	modem.Handle("AT+CMGR=", func(cmd string) string {
+		return fmt.Sprintf("+CMGR: 0,,20\r\n%s\r\nOK\r\n", pdus[cmd])
+	})
+	ec := newTestEC600N(t, modem)
+
+	events, unsubscribe := ec.Subscribe(EventSMSReceived)
+	defer unsubscribe()
+
+	modem.Emit("+CMTI: \"SM\",2\r\n")
+	modem.Emit("+CMTI: \"SM\",1\r\n")
+
+	ev := waitEvent(t, events)
+	require.NotNil(t, ev.Message)
+	assert.Equal(t, "13800138000", ev.Message.Sender)
+	assert.Equal(t, "你好", ev.Message.Text)
+	assert.Equal(t, 2, ev.Message.Parts)
+
+	assert.Eventually(t, func() bool {
+		commands := strings.Join(modem.Commands(), "\n")
+		return strings.Contains(commands, "AT+CMGD=1") && strings.Contains(commands, "AT+CMGD=2")
+	}, time.Second, 10*time.Millisecond)
+
+	messages := ec.Inbox().List(10)
+	require.Len(t, messages, 1)
+	assert.Equal(t, int64(1), messages[0].ID)
+}
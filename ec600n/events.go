@@ -0,0 +1,221 @@
+package ec600n
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	EventBufferSize = 32 // 每个订阅者的事件缓冲长度
+)
+
+// EventType 模块主动上报事件（URC）类型
+type EventType string
+
+const (
+	EventRing       EventType = "RING"       // 来电振铃
+	EventCallerID   EventType = "CLIP"       // 来电号码 +CLIP
+	EventNoCarrier  EventType = "NO_CARRIER" // 通话结束 NO CARRIER
+	EventBusy       EventType = "BUSY"       // 对方忙 BUSY
+	EventNoAnswer   EventType = "NO_ANSWER"  // 无人接听 NO ANSWER
+	EventNewSMS     EventType = "CMTI"       // 新短信到达 +CMTI
+	EventIndication EventType = "QIND"       // 模块状态指示 +QIND
+	EventNetworkReg EventType = "CREG"       // 网络注册状态变化 +CREG
+	EventDTMF       EventType = "DTMF"       // 通话中检测到按键 +QTONEDET
+	EventPlayback   EventType = "PLAYBACK"   // TTS/音频播放状态 +QTTS/+QPSND
+
+	EventSMSReceived EventType = "SMS" // 收到完整短信（由 +CMTI 触发读取并合并分段后发布）
+)
+
+// urcPrefixes 带参数的 URC 前缀与事件类型映射
+var urcPrefixes = map[string]EventType{
+	"+CLIP:":     EventCallerID,
+	"+CMTI:":     EventNewSMS,
+	"+QIND:":     EventIndication,
+	"+CREG:":     EventNetworkReg,
+	"+QTONEDET:": EventDTMF,
+	"+QTTS:":     EventPlayback,
+	"+QPSND:":    EventPlayback,
+}
+
+// urcCodes 无参数的 URC 与事件类型映射
+var urcCodes = map[string]EventType{
+	"RING":       EventRing,
+	"NO CARRIER": EventNoCarrier,
+	"BUSY":       EventBusy,
+	"NO ANSWER":  EventNoAnswer,
+}
+
+// Event 模块主动上报事件
+type Event struct {
+	Type    EventType `json:"type"`
+	Raw     string    `json:"raw"`               // 原始 URC 行
+	Params  []string  `json:"params,omitempty"`  // 按逗号拆分的参数（已去除引号）
+	Number  string    `json:"number,omitempty"`  // 来电号码（CLIP）
+	Storage string    `json:"storage,omitempty"` // 短信存储区（CMTI）
+	Index   int       `json:"index,omitempty"`   // 短信存储索引（CMTI）
+	Status  string    `json:"status,omitempty"`  // 网络注册状态码（CREG）
+	Digit   string    `json:"digit,omitempty"`   // 按键（DTMF）
+	Time    time.Time `json:"time"`
+
+	Message *SMSMessage `json:"message,omitempty"` // 收到的短信（SMS）
+}
+
+// parseURC 判断一行数据是否为 URC 并解析为事件
+// command 为当前正在执行的指令（空闲时为空），用于区分指令响应与同名 URC：
+// 例如 AT+CREG? 的 +CREG 响应、ATD 的 NO CARRIER 结果码都属于指令响应
+func parseURC(line, command string) (Event, bool) {
+	if typ, ok := urcCodes[line]; ok {
+		if isDialCommand(command) {
+			return Event{}, false
+		}
+		return Event{Type: typ, Raw: line, Time: time.Now()}, true
+	}
+
+	for prefix, typ := range urcPrefixes {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		if responsePrefix(command) == prefix {
+			return Event{}, false
+		}
+
+		ev := Event{Type: typ, Raw: line, Time: time.Now()}
+		ev.Params = splitParams(strings.TrimPrefix(line, prefix))
+		switch typ {
+		case EventCallerID:
+			if len(ev.Params) > 0 {
+				ev.Number = ev.Params[0]
+			}
+		case EventNewSMS:
+			if len(ev.Params) > 1 {
+				ev.Storage = ev.Params[0]
+				ev.Index, _ = strconv.Atoi(ev.Params[1])
+			}
+		case EventNetworkReg:
+			// URC 格式为 +CREG: <stat>[,<lac>,<ci>]
+			if len(ev.Params) > 0 {
+				ev.Status = ev.Params[0]
+			}
+		case EventDTMF:
+			if len(ev.Params) > 0 {
+				ev.Digit = parseDTMFDigit(ev.Params[0])
+			}
+		}
+		return ev, true
+	}
+
+	return Event{}, false
+}
+
+// parseDTMFDigit 解析 +QTONEDET 上报的按键
+// 模块上报按键的 ASCII 码（如 49 表示 1），部分固件直接上报按键字符
+func parseDTMFDigit(code string) string {
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return code
+	}
+	if n >= '#' {
+		return string(rune(n))
+	}
+	return strconv.Itoa(n)
+}
+
+// isDialCommand 判断是否为拨号/接听指令，这类指令以 NO CARRIER 等作为最终结果码
+func isDialCommand(command string) bool {
+	upper := strings.ToUpper(command)
+	return strings.HasPrefix(upper, "ATD") || upper == "ATA"
+}
+
+// responsePrefix 返回指令对应的响应前缀，例如 AT+CREG? -> +CREG:
+func responsePrefix(command string) string {
+	if !strings.HasPrefix(strings.ToUpper(command), "AT+") {
+		return ""
+	}
+	name := command[2:]
+	if idx := strings.IndexAny(name, "=?"); idx >= 0 {
+		name = name[:idx]
+	}
+	return strings.ToUpper(name) + ":"
+}
+
+// splitParams 按逗号拆分 URC 参数并去除引号与空白
+func splitParams(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"`)
+	}
+	return parts
+}
+
+// subscription 事件订阅
+type subscription struct {
+	ch    chan Event
+	types map[EventType]bool
+}
+
+// EventBus 模块事件总线
+// 订阅者通过 Subscribe 获取事件通道；发布不阻塞，订阅者处理过慢时丢弃事件
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[int]*subscription
+	nextID int
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*subscription)}
+}
+
+// Subscribe 订阅指定类型的事件，types 为空表示订阅全部事件
+// 返回事件通道与取消订阅函数，取消订阅后通道会被关闭
+func (b *EventBus) Subscribe(types ...EventType) (<-chan Event, func()) {
+	sub := &subscription{ch: make(chan Event, EventBufferSize)}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			close(sub.ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish 发布事件给所有匹配的订阅者
+func (b *EventBus) Publish(ev Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if sub.types != nil && !sub.types[ev.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			zap.S().Warnf("事件订阅者处理过慢，丢弃事件: %s", ev.Raw)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package ec600n
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultATTimeout  = 2 * time.Second        // 普通 AT 指令默认超时时间
+	DialTimeout       = 15 * time.Second       // 拨号指令超时时间
+	SerialReadTimeout = 500 * time.Millisecond // 串口单次读取超时，用于读协程感知关闭
+	ATQueueSize       = 16                     // AT 指令队列长度
+	ResyncQuietPeriod = 300 * time.Millisecond // 重新同步时串口无输出的等待时间
+	lineBufferSize    = 64                     // 串口行缓冲长度
+	dataPrompt        = ">"                    // 数据输入提示符（如 AT+CMGS 后的 "> "）
+	ctrlZ             = "\x1a"                 // 数据输入结束符
+	escape            = "\x1b"                 // 取消数据输入
+)
+
+var (
+	// ErrNotConnected 串口未连接
+	ErrNotConnected = errors.New("串口未连接")
+	// ErrATTimeout AT 指令响应超时
+	ErrATTimeout = errors.New("AT 指令响应超时")
+	// ErrExecutorStopped AT 指令执行器已停止
+	ErrExecutorStopped = errors.New("AT 指令执行器已停止")
+)
+
+// ATResult AT 指令执行结果
+type ATResult struct {
+	Command  string        // 发送的指令
+	Lines    []string      // 中间响应行（不含回显和最终结果码）
+	Final    string        // 最终结果码，如 OK、ERROR、+CME ERROR: 10、NO CARRIER
+	Duration time.Duration // 执行耗时
+}
+
+// OK 判断指令是否执行成功
+func (r *ATResult) OK() bool {
+	return r.Final == "OK"
+}
+
+// Text 返回完整响应文本（中间响应行与最终结果码），便于正则解析
+func (r *ATResult) Text() string {
+	lines := make([]string, 0, len(r.Lines)+1)
+	lines = append(lines, r.Lines...)
+	if r.Final != "" {
+		lines = append(lines, r.Final)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Err 指令未返回 OK 时给出错误
+func (r *ATResult) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("AT 指令 [%s] 执行失败，响应: %s", r.Command, r.Text())
+}
+
+// atRequest 排队等待执行的 AT 指令
+type atRequest struct {
+	ctx     context.Context
+	command string
+	data    string // 收到 "> " 提示符后写入的数据，为空表示普通指令
+	timeout time.Duration
+	reply   chan atReply
+}
+
+// atReply AT 指令执行应答
+type atReply struct {
+	result *ATResult
+	err    error
+}
+
+// atExecutor AT 指令执行器
+// 串口由唯一的执行协程独占写入，所有指令经队列串行执行，避免响应串扰；
+// 读协程持续读取串口，执行协程将 URC 与指令响应分离，URC 发布到事件总线
+type atExecutor struct {
+	port  io.ReadWriteCloser
+	bus   *EventBus
+	queue chan *atRequest
+	lines chan string
+
+	// 超时或取消后未收到最终结果码的指令，执行下一条指令前需要重新同步，避免迟到的结果码被当作下一条指令的响应
+	stale *atRequest
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// newATExecutor 创建并启动 AT 指令执行器
+func newATExecutor(port io.ReadWriteCloser, bus *EventBus) *atExecutor {
+	x := &atExecutor{
+		port:  port,
+		bus:   bus,
+		queue: make(chan *atRequest, ATQueueSize),
+		lines: make(chan string, lineBufferSize),
+		done:  make(chan struct{}),
+	}
+
+	x.wg.Add(2)
+	go x.readLoop()
+	go x.run()
+	return x
+}
+
+// Exec 提交 AT 指令并等待执行结果
+// ctx 取消或超时后立即返回，执行器不会再等待该指令的响应
+func (x *atExecutor) Exec(ctx context.Context, command string, timeout time.Duration) (*ATResult, error) {
+	return x.ExecWithData(ctx, command, "", timeout)
+}
+
+// ExecWithData 提交需要二次输入数据的 AT 指令（如 AT+CMGS），
+// 收到 "> " 提示符后写入 data 并以 Ctrl-Z 结束，再等待最终结果码
+func (x *atExecutor) ExecWithData(ctx context.Context, command, data string, timeout time.Duration) (*ATResult, error) {
+	if timeout <= 0 {
+		timeout = DefaultATTimeout
+	}
+
+	req := &atRequest{
+		ctx:     ctx,
+		command: command,
+		data:    data,
+		timeout: timeout,
+		reply:   make(chan atReply, 1),
+	}
+
+	select {
+	case x.queue <- req:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-x.done:
+		return nil, ErrExecutorStopped
+	}
+
+	select {
+	case r := <-req.reply:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-x.done:
+		return nil, ErrExecutorStopped
+	}
+}
+
+// Close 停止执行器并关闭串口
+func (x *atExecutor) Close() error {
+	var err error
+	x.closeOnce.Do(func() {
+		close(x.done)
+		err = x.port.Close()
+		x.wg.Wait()
+	})
+	return err
+}
+
+// run 执行协程，按队列顺序逐条执行指令
+func (x *atExecutor) run() {
+	defer x.wg.Done()
+
+	for {
+		select {
+		case <-x.done:
+			return
+		case line := <-x.lines:
+			x.handleIdleLine(line)
+		case req := <-x.queue:
+			if x.stale != nil {
+				x.resync()
+			}
+			x.execute(req)
+		}
+	}
+}
+
+// resync 丢弃上一条未完成指令的剩余响应
+// 先等待其最终结果码直到串口静默；仍未收到时发送 AT，收到 OK 后再等待串口静默，
+// 确保上一条指令迟到的结果码与 AT 的响应都已被丢弃
+func (x *atExecutor) resync() {
+	stale := x.stale
+	x.stale = nil
+	zap.S().Debugf("重新同步 AT 指令响应: 上一条指令 [%s] 未完成", stale.command)
+
+	if x.drain(ResyncQuietPeriod) {
+		return
+	}
+	if stale.data != "" {
+		// 上一条指令可能仍在等待数据输入，先取消输入，避免 AT 被当作数据内容
+		if _, err := x.port.Write([]byte(escape)); err != nil {
+			zap.S().Errorf("取消数据输入失败: %v", err)
+		}
+	}
+	if _, err := x.port.Write([]byte("AT\r\n")); err != nil {
+		zap.S().Errorf("发送同步指令失败: %v", err)
+		return
+	}
+
+	timer := time.NewTimer(DefaultATTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-x.done:
+			return
+		case <-timer.C:
+			zap.S().Warn("同步指令响应超时")
+			return
+		case line := <-x.lines:
+			if ev, ok := parseURC(line, ""); ok {
+				x.bus.Publish(ev)
+				continue
+			}
+			zap.S().Debugf("丢弃未完成指令的响应: %s", line)
+			if line == "OK" {
+				x.drain(ResyncQuietPeriod)
+				return
+			}
+		}
+	}
+}
+
+// drain 丢弃串口输出直到静默 quiet 时长，URC 照常发布；返回期间是否收到最终结果码
+func (x *atExecutor) drain(quiet time.Duration) bool {
+	final := false
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case <-x.done:
+			return final
+		case <-timer.C:
+			return final
+		case line := <-x.lines:
+			if ev, ok := parseURC(line, ""); ok {
+				x.bus.Publish(ev)
+			} else {
+				zap.S().Debugf("丢弃未完成指令的响应: %s", line)
+				final = final || isFinalResultCode(line)
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(quiet)
+		}
+	}
+}
+
+// handleIdleLine 处理没有指令在执行时收到的行
+// 收到未完成指令迟到的最终结果码时，不再需要重新同步
+func (x *atExecutor) handleIdleLine(line string) {
+	if ev, ok := parseURC(line, ""); ok {
+		x.bus.Publish(ev)
+		return
+	}
+	if x.stale != nil && isFinalResultCode(line) {
+		zap.S().Debugf("丢弃指令 [%s] 迟到的结果码: %s", x.stale.command, line)
+		x.stale = nil
+		return
+	}
+	zap.S().Debugf("丢弃非指令响应: %s", line)
+}
+
+// execute 发送一条指令并收集响应，直到收到最终结果码、超时或取消
+func (x *atExecutor) execute(req *atRequest) {
+	if err := req.ctx.Err(); err != nil {
+		req.reply <- atReply{err: err}
+		return
+	}
+
+	start := time.Now()
+	result := &ATResult{Command: req.command}
+
+	// 需要输入数据的指令只以 \r 结束，避免 \n 被模块当作数据内容
+	terminator := "\r\n"
+	if req.data != "" {
+		terminator = "\r"
+	}
+	if _, err := x.port.Write([]byte(req.command + terminator)); err != nil {
+		req.reply <- atReply{err: fmt.Errorf("发送 AT 指令失败: %w", err)}
+		return
+	}
+
+	timer := time.NewTimer(req.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-x.done:
+			req.reply <- atReply{err: ErrExecutorStopped}
+			return
+		case <-req.ctx.Done():
+			x.stale = req
+			req.reply <- atReply{err: req.ctx.Err()}
+			return
+		case <-timer.C:
+			x.stale = req
+			result.Duration = time.Since(start)
+			req.reply <- atReply{result: result, err: fmt.Errorf("%w [%s]", ErrATTimeout, req.command)}
+			return
+		case line := <-x.lines:
+			// 指令执行期间收到的 URC 不计入响应
+			if ev, ok := parseURC(line, req.command); ok {
+				x.bus.Publish(ev)
+				continue
+			}
+			// 跳过指令回显
+			if line == req.command {
+				continue
+			}
+			if line == dataPrompt && req.data != "" {
+				if _, err := x.port.Write([]byte(req.data + ctrlZ)); err != nil {
+					req.reply <- atReply{err: fmt.Errorf("发送数据失败: %w", err)}
+					return
+				}
+				continue
+			}
+			if isFinalResultCode(line) {
+				result.Final = line
+				result.Duration = time.Since(start)
+				req.reply <- atReply{result: result}
+				return
+			}
+			result.Lines = append(result.Lines, line)
+		}
+	}
+}
+
+// readLoop 读协程，持续从串口读取数据并按行投递给执行协程
+func (x *atExecutor) readLoop() {
+	defer x.wg.Done()
+
+	buf := make([]byte, 256)
+	var pending []byte
+	for {
+		select {
+		case <-x.done:
+			return
+		default:
+		}
+
+		n, err := x.port.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			for {
+				var line string
+				if idx := bytes.IndexByte(pending, '\n'); idx >= 0 {
+					line = strings.TrimSpace(string(pending[:idx]))
+					pending = pending[idx+1:]
+				} else if strings.TrimSpace(string(pending)) == dataPrompt {
+					// 数据输入提示符 "> " 后没有换行，需要单独识别
+					line = dataPrompt
+					pending = pending[:0]
+				} else {
+					break
+				}
+				if line == "" {
+					continue
+				}
+				select {
+				case x.lines <- line:
+				case <-x.done:
+					return
+				}
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			select {
+			case <-x.done:
+			default:
+				zap.S().Errorf("读取串口数据失败: %v", err)
+				time.Sleep(SerialReadTimeout)
+			}
+		}
+	}
+}
+
+// isFinalResultCode 判断是否为 AT 指令最终结果码
+func isFinalResultCode(line string) bool {
+	switch line {
+	case "OK", "ERROR", "NO CARRIER", "BUSY", "NO ANSWER", "NO DIALTONE":
+		return true
+	}
+	return strings.HasPrefix(line, "CONNECT") ||
+		strings.HasPrefix(line, "+CME ERROR:") ||
+		strings.HasPrefix(line, "+CMS ERROR:")
+}
@@ -0,0 +1,271 @@
+package ec600n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-mobile-notify/notification"
+	"alert-mobile-notify/storage"
+
+	"go.uber.org/zap"
+)
+
+const (
+	InboxFileName       = "sms_inbox.json" // 短信收件箱文件名
+	InboxMaxMessages    = 500              // 收件箱最多保留的短信条数
+	InboundSMSTimeout   = 30 * time.Second // 单条短信处理超时时间
+	PartialSMSTimeout   = 10 * time.Minute // 级联短信分段等待超时，超时后按已收到的分段处理
+	partialSMSCheckTick = time.Minute      // 检查超时分段的间隔
+)
+
+// SMSMessage 收到的完整短信
+type SMSMessage struct {
+	ID         int64     `json:"id"`
+	Sender     string    `json:"sender"`
+	Text       string    `json:"text"`
+	SentAt     time.Time `json:"sent_at"`     // 短信中心时间戳
+	ReceivedAt time.Time `json:"received_at"` // 本地接收时间
+	Parts      int       `json:"parts"`       // 分段数
+	Incomplete bool      `json:"incomplete,omitempty"`
+}
+
+// SMSInbox 短信收件箱，保存最近收到的短信并持久化到本地文件
+type SMSInbox struct {
+	mu       sync.RWMutex
+	file     *storage.JSONFile
+	messages []*SMSMessage
+	nextID   int64
+}
+
+// NewSMSInbox 创建短信收件箱，file 为 nil 时仅保存在内存中
+func NewSMSInbox(file *storage.JSONFile) (*SMSInbox, error) {
+	inbox := &SMSInbox{file: file, nextID: 1}
+	if file == nil {
+		return inbox, nil
+	}
+
+	if _, err := file.Load(&inbox.messages); err != nil {
+		return nil, fmt.Errorf("加载短信收件箱失败: %w", err)
+	}
+	for _, m := range inbox.messages {
+		if m.ID >= inbox.nextID {
+			inbox.nextID = m.ID + 1
+		}
+	}
+	return inbox, nil
+}
+
+// Add 保存一条短信并分配 ID
+func (b *SMSInbox) Add(msg *SMSMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg.ID = b.nextID
+	b.nextID++
+	b.messages = append(b.messages, msg)
+	if len(b.messages) > InboxMaxMessages {
+		b.messages = b.messages[len(b.messages)-InboxMaxMessages:]
+	}
+
+	if b.file == nil {
+		return nil
+	}
+	return b.file.Save(b.messages)
+}
+
+// List 按接收时间倒序返回最近的短信，limit <= 0 表示全部
+func (b *SMSInbox) List(limit int) []*SMSMessage {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n := len(b.messages)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	out := make([]*SMSMessage, 0, limit)
+	for i := n - 1; i >= n-limit; i-- {
+		out = append(out, b.messages[i])
+	}
+	return out
+}
+
+// partialSMS 等待合并的级联短信
+type partialSMS struct {
+	parts     map[int]*SMSPart
+	total     int
+	firstSeen time.Time
+}
+
+// smsAssembler 级联短信分段合并
+type smsAssembler struct {
+	pending map[string]*partialSMS
+}
+
+func newSMSAssembler() *smsAssembler {
+	return &smsAssembler{pending: make(map[string]*partialSMS)}
+}
+
+// Add 加入一个分段，全部分段到齐后返回按序排列的分段
+func (a *smsAssembler) Add(part *SMSPart) []*SMSPart {
+	if part.Total <= 1 {
+		return []*SMSPart{part}
+	}
+
+	key := fmt.Sprintf("%s/%d/%d", part.Sender, part.Ref, part.Total)
+	p, ok := a.pending[key]
+	if !ok {
+		p = &partialSMS{parts: make(map[int]*SMSPart), total: part.Total, firstSeen: time.Now()}
+		a.pending[key] = p
+	}
+	p.parts[part.Seq] = part
+
+	if len(p.parts) < p.total {
+		return nil
+	}
+	delete(a.pending, key)
+	return p.sorted()
+}
+
+// Expired 取出等待超时的级联短信
+func (a *smsAssembler) Expired(timeout time.Duration) [][]*SMSPart {
+	var out [][]*SMSPart
+	for key, p := range a.pending {
+		if time.Since(p.firstSeen) >= timeout {
+			delete(a.pending, key)
+			out = append(out, p.sorted())
+		}
+	}
+	return out
+}
+
+func (p *partialSMS) sorted() []*SMSPart {
+	parts := make([]*SMSPart, 0, len(p.parts))
+	for _, part := range p.parts {
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Seq < parts[j].Seq })
+	return parts
+}
+
+// Inbox 返回短信收件箱
+func (e *EC600N) Inbox() *SMSInbox {
+	return e.inbox
+}
+
+// runInboundSMS 处理收到的短信：读取、合并分段、转发通知、存储并从 SIM 卡删除
+// 启动时先处理 SIM 卡中已有的短信，之后根据 +CMTI 上报逐条处理
+func (e *EC600N) runInboundSMS(events <-chan Event) {
+	e.processStoredSMS()
+
+	assembler := newSMSAssembler()
+	ticker := time.NewTicker(partialSMSCheckTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), InboundSMSTimeout)
+			part, err := e.ReadSMS(ctx, ev.Storage, ev.Index)
+			cancel()
+			if err != nil {
+				zap.S().Errorf("读取新短信失败 [%s:%d]: %v", ev.Storage, ev.Index, err)
+				continue
+			}
+			if parts := assembler.Add(part); parts != nil {
+				e.handleInboundSMS(parts, false)
+			}
+		case <-ticker.C:
+			for _, parts := range assembler.Expired(PartialSMSTimeout) {
+				e.handleInboundSMS(parts, true)
+			}
+		}
+	}
+}
+
+// processStoredSMS 处理 SIM 卡中已有的短信（服务停止期间收到的短信）
+func (e *EC600N) processStoredSMS() {
+	ctx, cancel := context.WithTimeout(context.Background(), InboundSMSTimeout)
+	parts, err := e.ListSMS(ctx, "")
+	cancel()
+	if err != nil {
+		zap.S().Warnf("读取 SIM 卡已有短信失败: %v", err)
+		return
+	}
+
+	assembler := newSMSAssembler()
+	for _, part := range parts {
+		if complete := assembler.Add(part); complete != nil {
+			e.handleInboundSMS(complete, false)
+		}
+	}
+	// 启动时不再等待缺失的分段
+	for _, incomplete := range assembler.Expired(0) {
+		e.handleInboundSMS(incomplete, true)
+	}
+}
+
+// handleInboundSMS 合并分段后存储、转发并发布事件，最后从 SIM 卡删除已处理的分段
+func (e *EC600N) handleInboundSMS(parts []*SMSPart, incomplete bool) {
+	var text strings.Builder
+	for _, part := range parts {
+		text.WriteString(part.Text)
+	}
+
+	first := parts[0]
+	msg := &SMSMessage{
+		Sender:     first.Sender,
+		Text:       text.String(),
+		SentAt:     first.SentAt,
+		ReceivedAt: time.Now(),
+		Parts:      len(parts),
+		Incomplete: incomplete,
+	}
+	zap.S().Infof("收到短信: 发件人=%s, 分段数=%d, 内容=%s", msg.Sender, msg.Parts, msg.Text)
+
+	if err := e.inbox.Add(msg); err != nil {
+		zap.S().Errorf("保存短信失败: %v", err)
+	}
+
+	if e.notify != nil {
+		message := &notification.Message{
+			Event: notification.EventSMS,
+			Title: "📩 收到短信",
+			Fields: []notification.Field{
+				{Name: "发件人", Value: msg.Sender},
+				{Name: "时间", Value: msg.SentAt.Format("2006-01-02 15:04:05")},
+				{Name: "内容", Value: msg.Text},
+			},
+		}
+		if incomplete {
+			message.Note = "（部分分段缺失）"
+		}
+		if err := e.notify.Send(message); err != nil {
+			zap.S().Errorf("转发短信通知失败: %v", err)
+		}
+	}
+
+	e.events.Publish(Event{
+		Type:    EventSMSReceived,
+		Raw:     msg.Text,
+		Number:  msg.Sender,
+		Message: msg,
+		Time:    msg.ReceivedAt,
+	})
+
+	for _, part := range parts {
+		ctx, cancel := context.WithTimeout(context.Background(), InboundSMSTimeout)
+		if err := e.DeleteSMS(ctx, part.Storage, part.Index); err != nil {
+			zap.S().Errorf("删除已处理短信失败: %v", err)
+		}
+		cancel()
+	}
+}
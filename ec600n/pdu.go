@@ -0,0 +1,495 @@
+package ec600n
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	SMSEncodingGSM7 = "gsm7" // GSM 7-bit 默认字母表
+	SMSEncodingUCS2 = "ucs2" // UCS2（UTF-16BE），用于中文等非 GSM 字符
+
+	gsm7SingleLimit = 160 // 单条 GSM 7-bit 短信最大 septet 数
+	gsm7PartLimit   = 153 // 长短信每个分段最大 septet 数（扣除 UDH）
+	ucs2SingleLimit = 70  // 单条 UCS2 短信最大字符数
+	ucs2PartLimit   = 67  // 长短信每个分段最大字符数（扣除 UDH）
+
+	SMSEncoding8Bit = "8bit" // 8-bit 数据
+
+	gsm7Escape = 0x1B // GSM 扩展字符表转义符
+	dcsGSM7    = 0x00 // TP-DCS: GSM 7-bit
+	dcsUCS2    = 0x08 // TP-DCS: UCS2
+)
+
+// gsm7Alphabet GSM 03.38 默认字母表，下标即编码值
+var gsm7Alphabet = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension GSM 03.38 扩展字符表（需以 0x1B 转义）
+var gsm7Extension = map[byte]rune{
+	0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+	0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+}
+
+var (
+	gsm7EncodeMap    = make(map[rune]byte, len(gsm7Alphabet))
+	gsm7ExtEncodeMap = make(map[rune]byte, len(gsm7Extension))
+)
+
+func init() {
+	for i, r := range gsm7Alphabet {
+		if r != gsm7Escape {
+			gsm7EncodeMap[r] = byte(i)
+		}
+	}
+	for code, r := range gsm7Extension {
+		gsm7ExtEncodeMap[r] = code
+	}
+}
+
+// submitPDU 待发送的 SMS-SUBMIT PDU
+type submitPDU struct {
+	Hex     string // 完整 PDU 十六进制串（含 SMSC 字段）
+	TPDULen int    // AT+CMGS 所需长度（不含 SMSC 字段）
+}
+
+// gsm7Encode 将文本编码为 GSM 7-bit septet 序列，包含不支持的字符时返回 false
+func gsm7Encode(text string) ([]byte, bool) {
+	septets := make([]byte, 0, len(text))
+	for _, r := range text {
+		if code, ok := gsm7EncodeMap[r]; ok {
+			septets = append(septets, code)
+			continue
+		}
+		if code, ok := gsm7ExtEncodeMap[r]; ok {
+			septets = append(septets, gsm7Escape, code)
+			continue
+		}
+		return nil, false
+	}
+	return septets, true
+}
+
+// gsm7Decode 将 GSM 7-bit septet 序列解码为文本
+func gsm7Decode(septets []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(septets); i++ {
+		c := septets[i] & 0x7F
+		if c == gsm7Escape && i+1 < len(septets) {
+			i++
+			if r, ok := gsm7Extension[septets[i]&0x7F]; ok {
+				sb.WriteRune(r)
+			} else {
+				sb.WriteRune(' ')
+			}
+			continue
+		}
+		sb.WriteRune(gsm7Alphabet[c])
+	}
+	return sb.String()
+}
+
+// packSeptets 将 septet 序列打包为 octet，fillBits 为 UDH 之后的填充位数
+func packSeptets(septets []byte, fillBits int) []byte {
+	totalBits := fillBits + len(septets)*7
+	out := make([]byte, (totalBits+7)/8)
+	for i, s := range septets {
+		pos := fillBits + i*7
+		idx, shift := pos/8, pos%8
+		out[idx] |= s << shift
+		if shift > 1 {
+			out[idx+1] |= s >> (8 - shift)
+		}
+	}
+	return out
+}
+
+// splitSeptets 按分段上限拆分 septet 序列，不拆开转义字符
+func splitSeptets(septets []byte, limit int) [][]byte {
+	var parts [][]byte
+	for len(septets) > 0 {
+		n := limit
+		if n >= len(septets) {
+			n = len(septets)
+		} else if septets[n-1] == gsm7Escape {
+			n--
+		}
+		parts = append(parts, septets[:n])
+		septets = septets[n:]
+	}
+	return parts
+}
+
+// splitUCS2 按分段上限拆分 UTF-16 编码单元，不拆开代理对
+func splitUCS2(units []uint16, limit int) [][]uint16 {
+	var parts [][]uint16
+	for len(units) > 0 {
+		n := limit
+		if n >= len(units) {
+			n = len(units)
+		} else if utf16.IsSurrogate(rune(units[n-1])) && units[n-1] < 0xDC00 {
+			n--
+		}
+		parts = append(parts, units[:n])
+		units = units[n:]
+	}
+	return parts
+}
+
+// ucs2Bytes 将 UTF-16 编码单元转换为大端字节序
+func ucs2Bytes(units []uint16) []byte {
+	out := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+// ucs2Hex 将文本编码为 UCS2 十六进制串（用于文本模式下 AT+CSCS="UCS2"）
+func ucs2Hex(text string) string {
+	return strings.ToUpper(hex.EncodeToString(ucs2Bytes(utf16.Encode([]rune(text)))))
+}
+
+// encodeAddress 编码目的地址：长度（数字个数）+ 号码类型 + 半字节反转的 BCD 号码
+func encodeAddress(number string) (string, error) {
+	toa := byte(0x81)
+	if strings.HasPrefix(number, "+") {
+		toa = 0x91
+		number = number[1:]
+	}
+	if number == "" {
+		return "", fmt.Errorf("电话号码不能为空")
+	}
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("电话号码包含非法字符: %s", number)
+		}
+	}
+
+	digits := number
+	if len(digits)%2 == 1 {
+		digits += "F"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%02X%02X", len(number), toa)
+	for i := 0; i < len(digits); i += 2 {
+		sb.WriteByte(digits[i+1])
+		sb.WriteByte(digits[i])
+	}
+	return sb.String(), nil
+}
+
+// buildSubmitPDUs 构造 SMS-SUBMIT PDU
+// 文本可用 GSM 7-bit 表示时使用 GSM 7-bit，否则使用 UCS2；超长时拆分为带 UDH 的级联短信，ref 为级联参考号
+func buildSubmitPDUs(number, text string, ref byte) ([]submitPDU, string, error) {
+	address, err := encodeAddress(number)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		encoding string
+		dcs      byte
+		payloads [][]byte // 每段用户数据（不含 UDH）
+		lengths  []int    // 每段 TP-UDL（不含 UDH）
+	)
+
+	if septets, ok := gsm7Encode(text); ok {
+		encoding, dcs = SMSEncodingGSM7, dcsGSM7
+		parts := [][]byte{septets}
+		if len(septets) > gsm7SingleLimit {
+			parts = splitSeptets(septets, gsm7PartLimit)
+		}
+		for _, p := range parts {
+			payloads = append(payloads, p)
+			lengths = append(lengths, len(p))
+		}
+	} else {
+		encoding, dcs = SMSEncodingUCS2, dcsUCS2
+		units := utf16.Encode([]rune(text))
+		parts := [][]uint16{units}
+		if len(units) > ucs2SingleLimit {
+			parts = splitUCS2(units, ucs2PartLimit)
+		}
+		for _, p := range parts {
+			b := ucs2Bytes(p)
+			payloads = append(payloads, b)
+			lengths = append(lengths, len(b))
+		}
+	}
+
+	total := len(payloads)
+	pdus := make([]submitPDU, 0, total)
+	for i, payload := range payloads {
+		var udh []byte
+		firstOctet := byte(0x11) // SMS-SUBMIT，相对有效期
+		if total > 1 {
+			firstOctet |= 0x40 // TP-UDHI
+			udh = []byte{0x05, 0x00, 0x03, ref, byte(total), byte(i + 1)}
+		}
+
+		var ud []byte
+		var udl int
+		if encoding == SMSEncodingGSM7 {
+			// UDH 之后需填充到 septet 边界
+			headerSeptets := (len(udh)*8 + 6) / 7
+			fill := headerSeptets*7 - len(udh)*8
+			ud = append(udh, packSeptets(payload, fill)...)
+			udl = headerSeptets + lengths[i]
+		} else {
+			ud = append(udh, payload...)
+			udl = len(ud)
+		}
+
+		// TP-MTI/标志, TP-MR, TP-DA, TP-PID, TP-DCS, TP-VP(4天), TP-UDL, TP-UD
+		tpdu := fmt.Sprintf("%02X00%s00%02XAA%02X%s", firstOctet, address, dcs, udl, strings.ToUpper(hex.EncodeToString(ud)))
+		pdus = append(pdus, submitPDU{
+			Hex:     "00" + tpdu,
+			TPDULen: len(tpdu) / 2,
+		})
+	}
+
+	return pdus, encoding, nil
+}
+
+// SMSPart 收到的单条短信（级联短信的一个分段），由 SMS-DELIVER PDU 解析而来
+type SMSPart struct {
+	Storage  string    // 存储区，如 SM、ME
+	Index    int       // 存储索引
+	Sender   string    // 发件人号码或名称
+	SentAt   time.Time // 短信中心时间戳
+	Encoding string    // 编码 gsm7/ucs2/8bit
+	Text     string    // 分段内容
+
+	// 级联短信信息，Total 为 0 表示非级联短信
+	Ref   int
+	Total int
+	Seq   int
+}
+
+// pduReader 按字节顺序读取 PDU
+type pduReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *pduReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("PDU 长度不足")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *pduReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("PDU 长度不足")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// decodeDeliverPDU 解析 AT+CMGR/AT+CMGL 返回的 SMS-DELIVER PDU（含 SMSC 字段）
+func decodeDeliverPDU(pduHex string) (*SMSPart, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return nil, fmt.Errorf("PDU 不是有效的十六进制串: %w", err)
+	}
+	r := &pduReader{data: data}
+
+	// SMSC 地址
+	scaLen, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(int(scaLen)); err != nil {
+		return nil, err
+	}
+
+	firstOctet, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	if firstOctet&0x03 != 0x00 {
+		return nil, fmt.Errorf("不是 SMS-DELIVER 类型的 PDU: 0x%02X", firstOctet)
+	}
+	hasUDH := firstOctet&0x40 != 0
+
+	// 发件人地址
+	oaDigits, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	toa, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	oa, err := r.bytes((int(oaDigits) + 1) / 2)
+	if err != nil {
+		return nil, err
+	}
+
+	pdu := &SMSPart{Sender: decodeAddress(oa, int(oaDigits), toa)}
+
+	// TP-PID、TP-DCS、TP-SCTS
+	if _, err := r.byte(); err != nil {
+		return nil, err
+	}
+	dcs, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	scts, err := r.bytes(7)
+	if err != nil {
+		return nil, err
+	}
+	pdu.SentAt = decodeTimestamp(scts)
+	pdu.Encoding = dcsEncoding(dcs)
+
+	udl, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	ud := r.data[r.pos:]
+
+	// 用户数据头
+	udhLen := 0
+	if hasUDH {
+		if len(ud) == 0 {
+			return nil, fmt.Errorf("PDU 缺少用户数据头")
+		}
+		udhLen = int(ud[0]) + 1
+		if udhLen > len(ud) {
+			return nil, fmt.Errorf("PDU 用户数据头长度错误")
+		}
+		pdu.parseUDH(ud[1:udhLen])
+	}
+
+	switch pdu.Encoding {
+	case SMSEncodingGSM7:
+		septets := unpackSeptets(ud, int(udl))
+		skip := (udhLen*8 + 6) / 7
+		if skip > len(septets) {
+			skip = len(septets)
+		}
+		pdu.Text = gsm7Decode(septets[skip:])
+	case SMSEncodingUCS2:
+		end := int(udl)
+		if end > len(ud) {
+			end = len(ud)
+		}
+		pdu.Text = decodeUCS2(ud[udhLen:end])
+	default:
+		end := int(udl)
+		if end > len(ud) {
+			end = len(ud)
+		}
+		pdu.Text = string(ud[udhLen:end])
+	}
+
+	return pdu, nil
+}
+
+// parseUDH 解析用户数据头中的级联短信信息（8-bit 或 16-bit 参考号）
+func (p *SMSPart) parseUDH(udh []byte) {
+	for i := 0; i+1 < len(udh); {
+		iei, iel := udh[i], int(udh[i+1])
+		body := udh[i+2:]
+		if iel > len(body) {
+			return
+		}
+		body = body[:iel]
+		switch {
+		case iei == 0x00 && iel == 3:
+			p.Ref, p.Total, p.Seq = int(body[0]), int(body[1]), int(body[2])
+		case iei == 0x08 && iel == 4:
+			p.Ref, p.Total, p.Seq = int(body[0])<<8|int(body[1]), int(body[2]), int(body[3])
+		}
+		i += 2 + iel
+	}
+}
+
+// dcsEncoding 根据 TP-DCS 判断用户数据编码
+func dcsEncoding(dcs byte) string {
+	switch {
+	case dcs&0xC0 == 0x00:
+		switch dcs & 0x0C {
+		case 0x04:
+			return SMSEncoding8Bit
+		case 0x08:
+			return SMSEncodingUCS2
+		}
+		return SMSEncodingGSM7
+	case dcs&0xF0 == 0xE0:
+		return SMSEncodingUCS2
+	case dcs&0xF0 == 0xF0 && dcs&0x04 != 0:
+		return SMSEncoding8Bit
+	}
+	return SMSEncodingGSM7
+}
+
+// unpackSeptets 将 octet 解包为 count 个 septet
+func unpackSeptets(data []byte, count int) []byte {
+	septets := make([]byte, 0, count)
+	for i := 0; i < count; i++ {
+		pos := i * 7
+		idx, shift := pos/8, pos%8
+		if idx >= len(data) {
+			break
+		}
+		v := data[idx] >> shift
+		if shift > 1 && idx+1 < len(data) {
+			v |= data[idx+1] << (8 - shift)
+		}
+		septets = append(septets, v&0x7F)
+	}
+	return septets
+}
+
+// decodeUCS2 将 UCS2（UTF-16BE）字节解码为文本
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeAddress 解析地址字段，支持数字号码与字母数字发件人（如运营商名称）
+func decodeAddress(b []byte, digits int, toa byte) string {
+	if toa&0x70 == 0x50 {
+		return gsm7Decode(unpackSeptets(b, digits*4/7))
+	}
+
+	var sb strings.Builder
+	if toa&0x70 == 0x10 {
+		sb.WriteByte('+')
+	}
+	for _, o := range b {
+		for _, nibble := range []byte{o & 0x0F, o >> 4} {
+			if nibble == 0x0F {
+				continue
+			}
+			sb.WriteByte("0123456789*#abc"[nibble])
+		}
+	}
+	return sb.String()
+}
+
+// decodeTimestamp 解析 TP-SCTS 时间戳（半字节反转的 BCD，时区以 15 分钟为单位）
+func decodeTimestamp(b []byte) time.Time {
+	bcd := func(o byte) int { return int(o&0x0F)*10 + int(o>>4) }
+	tz := int(b[6]&0x07)*10 + int(b[6]>>4)
+	offset := tz * 15 * 60
+	if b[6]&0x08 != 0 {
+		offset = -offset
+	}
+	loc := time.FixedZone("", offset)
+	return time.Date(2000+bcd(b[0]), time.Month(bcd(b[1])), bcd(b[2]),
+		bcd(b[3]), bcd(b[4]), bcd(b[5]), 0, loc)
+}
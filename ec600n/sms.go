@@ -0,0 +1,281 @@
+package ec600n
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"go.uber.org/zap"
+)
+
+const (
+	SMSModePDU  = "pdu"  // PDU 模式，支持 UCS2 与级联长短信
+	SMSModeText = "text" // 文本模式，长短信拆分为多条独立短信
+
+	SMSSendTimeout = 60 * time.Second // AT+CMGS 发送超时时间
+)
+
+var (
+	reCMGS = regexp.MustCompile(`\+CMGS:\s*(\d+)`)
+	reCMGL = regexp.MustCompile(`\+CMGL:\s*(\d+),`)
+)
+
+// SMSResult 短信发送结果
+type SMSResult struct {
+	Number     string `json:"number"`
+	Mode       string `json:"mode"`       // 发送模式 pdu/text
+	Encoding   string `json:"encoding"`   // 编码 gsm7/ucs2
+	Parts      int    `json:"parts"`      // 分段数
+	References []int  `json:"references"` // 每个分段的消息参考号（+CMGS 返回的 <mr>）
+}
+
+// SendSMS 发送短信
+func (e *EC600N) SendSMS(phoneNumber, text string) (*SMSResult, error) {
+	return e.SendSMSContext(context.Background(), phoneNumber, text)
+}
+
+// SendSMSContext 发送短信，根据配置使用 PDU 模式（默认）或文本模式
+// 中文等非 GSM 字符使用 UCS2 编码，超长短信自动拆分
+func (e *EC600N) SendSMSContext(ctx context.Context, phoneNumber, text string) (*SMSResult, error) {
+	phoneNumber = normalizePhoneNumber(phoneNumber)
+	if phoneNumber == "" {
+		return nil, fmt.Errorf("电话号码不能为空")
+	}
+	if text == "" {
+		return nil, fmt.Errorf("短信内容不能为空")
+	}
+
+	// 短信指令序列需要切换 AT+CMGF 等全局设置，整个序列需互斥执行
+	e.smsMu.Lock()
+	defer e.smsMu.Unlock()
+
+	var (
+		result *SMSResult
+		err    error
+	)
+	if strings.EqualFold(e.config.EC600N.SMSMode, SMSModeText) {
+		result, err = e.sendTextSMS(ctx, phoneNumber, text)
+	} else {
+		result, err = e.sendPDUSMS(ctx, phoneNumber, text)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	zap.S().Infof("短信发送成功: %s, 分段数: %d, 参考号: %v", phoneNumber, result.Parts, result.References)
+	return result, nil
+}
+
+// sendPDUSMS 以 PDU 模式发送短信
+func (e *EC600N) sendPDUSMS(ctx context.Context, phoneNumber, text string) (*SMSResult, error) {
+	pdus, encoding, err := buildSubmitPDUs(phoneNumber, text, e.nextSMSRef())
+	if err != nil {
+		return nil, fmt.Errorf("构造短信 PDU 失败: %w", err)
+	}
+
+	if err := e.execOK(ctx, "AT+CMGF=0"); err != nil {
+		return nil, fmt.Errorf("设置 PDU 模式失败: %w", err)
+	}
+
+	result := &SMSResult{Number: phoneNumber, Mode: SMSModePDU, Encoding: encoding, Parts: len(pdus)}
+	for i, pdu := range pdus {
+		ref, err := e.submitSMS(ctx, fmt.Sprintf("AT+CMGS=%d", pdu.TPDULen), pdu.Hex)
+		if err != nil {
+			return result, fmt.Errorf("发送第 %d/%d 段短信失败: %w", i+1, len(pdus), err)
+		}
+		result.References = append(result.References, ref)
+	}
+	return result, nil
+}
+
+// sendTextSMS 以文本模式发送短信
+// 文本模式不支持级联，超长内容会拆分为多条独立短信
+func (e *EC600N) sendTextSMS(ctx context.Context, phoneNumber, text string) (*SMSResult, error) {
+	if err := e.execOK(ctx, "AT+CMGF=1"); err != nil {
+		return nil, fmt.Errorf("设置文本模式失败: %w", err)
+	}
+
+	result := &SMSResult{Number: phoneNumber, Mode: SMSModeText}
+	var command string
+	var parts []string
+
+	if septets, ok := gsm7Encode(text); ok {
+		result.Encoding = SMSEncodingGSM7
+		if err := e.execOK(ctx, `AT+CSCS="GSM"`); err != nil {
+			return nil, fmt.Errorf("设置字符集失败: %w", err)
+		}
+		if err := e.execOK(ctx, "AT+CSMP=17,167,0,0"); err != nil {
+			return nil, fmt.Errorf("设置短信参数失败: %w", err)
+		}
+		command = fmt.Sprintf(`AT+CMGS="%s"`, phoneNumber)
+		for _, p := range splitSeptets(septets, gsm7SingleLimit) {
+			parts = append(parts, gsm7Decode(p))
+		}
+	} else {
+		result.Encoding = SMSEncodingUCS2
+		// 字符集是模块全局设置，发送后恢复为 GSM，否则后续指令的字符串参数与响应中的号码都按 UCS2 十六进制编码
+		defer func() {
+			if err := e.resetCharset(context.Background()); err != nil {
+				zap.S().Warnf("恢复 GSM 字符集失败: %v", err)
+			}
+		}()
+		if err := e.execOK(ctx, `AT+CSCS="UCS2"`); err != nil {
+			return nil, fmt.Errorf("设置字符集失败: %w", err)
+		}
+		if err := e.execOK(ctx, "AT+CSMP=17,167,0,8"); err != nil {
+			return nil, fmt.Errorf("设置短信参数失败: %w", err)
+		}
+		command = fmt.Sprintf(`AT+CMGS="%s"`, ucs2Hex(phoneNumber))
+		for _, p := range splitUCS2(utf16.Encode([]rune(text)), ucs2SingleLimit) {
+			parts = append(parts, ucs2Hex(string(utf16.Decode(p))))
+		}
+	}
+
+	result.Parts = len(parts)
+	for i, part := range parts {
+		ref, err := e.submitSMS(ctx, command, part)
+		if err != nil {
+			return result, fmt.Errorf("发送第 %d/%d 段短信失败: %w", i+1, len(parts), err)
+		}
+		result.References = append(result.References, ref)
+	}
+	return result, nil
+}
+
+// ReadSMS 以 PDU 模式读取指定存储区与索引的短信
+func (e *EC600N) ReadSMS(ctx context.Context, storage string, index int) (*SMSPart, error) {
+	e.smsMu.Lock()
+	defer e.smsMu.Unlock()
+
+	if err := e.selectSMSStorage(ctx, storage); err != nil {
+		return nil, err
+	}
+
+	result, err := e.Exec(ctx, fmt.Sprintf("AT+CMGR=%d", index), DefaultATTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("读取短信失败: %w", err)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("读取短信失败: %w", err)
+	}
+
+	// 响应格式：+CMGR: <stat>,[<alpha>],<length> 后跟一行 PDU
+	for i, line := range result.Lines {
+		if strings.HasPrefix(line, "+CMGR:") && i+1 < len(result.Lines) {
+			part, err := decodeDeliverPDU(result.Lines[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("解析短信 PDU 失败 [%s:%d]: %w", storage, index, err)
+			}
+			part.Storage, part.Index = storage, index
+			return part, nil
+		}
+	}
+	return nil, fmt.Errorf("短信不存在 [%s:%d]", storage, index)
+}
+
+// ListSMS 以 PDU 模式列出存储区中的全部短信
+// 无法解析的 PDU（如状态报告）会被跳过
+func (e *EC600N) ListSMS(ctx context.Context, storage string) ([]*SMSPart, error) {
+	e.smsMu.Lock()
+	defer e.smsMu.Unlock()
+
+	if err := e.selectSMSStorage(ctx, storage); err != nil {
+		return nil, err
+	}
+
+	result, err := e.Exec(ctx, "AT+CMGL=4", SMSSendTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("列出短信失败: %w", err)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("列出短信失败: %w", err)
+	}
+
+	// 响应格式：每条短信为 +CMGL: <index>,<stat>,[<alpha>],<length> 后跟一行 PDU
+	var parts []*SMSPart
+	for i := 0; i+1 < len(result.Lines); i++ {
+		matches := reCMGL.FindStringSubmatch(result.Lines[i])
+		if matches == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(matches[1])
+		i++
+		part, err := decodeDeliverPDU(result.Lines[i])
+		if err != nil {
+			zap.S().Warnf("跳过无法解析的短信 [%s:%d]: %v", storage, index, err)
+			continue
+		}
+		part.Storage, part.Index = storage, index
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// DeleteSMS 删除指定存储区与索引的短信
+func (e *EC600N) DeleteSMS(ctx context.Context, storage string, index int) error {
+	e.smsMu.Lock()
+	defer e.smsMu.Unlock()
+
+	if err := e.selectSMSStorage(ctx, storage); err != nil {
+		return err
+	}
+	if err := e.execOK(ctx, fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+		return fmt.Errorf("删除短信失败 [%s:%d]: %w", storage, index, err)
+	}
+	return nil
+}
+
+// selectSMSStorage 切换到 PDU 模式并选择读取/删除所用的存储区，storage 为空时使用当前存储区
+func (e *EC600N) selectSMSStorage(ctx context.Context, storage string) error {
+	if err := e.execOK(ctx, "AT+CMGF=0"); err != nil {
+		return fmt.Errorf("设置 PDU 模式失败: %w", err)
+	}
+	if storage == "" {
+		return nil
+	}
+	if err := e.execOK(ctx, fmt.Sprintf(`AT+CPMS="%s"`, storage)); err != nil {
+		return fmt.Errorf("选择短信存储区失败 [%s]: %w", storage, err)
+	}
+	return nil
+}
+
+// submitSMS 执行 AT+CMGS 并解析消息参考号
+func (e *EC600N) submitSMS(ctx context.Context, command, data string) (int, error) {
+	result, err := e.ExecWithData(ctx, command, data, SMSSendTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if err := result.Err(); err != nil {
+		return 0, err
+	}
+
+	matches := reCMGS.FindStringSubmatch(result.Text())
+	if len(matches) < 2 {
+		return 0, fmt.Errorf("无法解析消息参考号，响应: %s", result.Text())
+	}
+	ref, _ := strconv.Atoi(matches[1])
+	return ref, nil
+}
+
+// resetCharset 将字符集设置为 GSM，号码等字符串参数与响应按 GSM 字符解析
+func (e *EC600N) resetCharset(ctx context.Context) error {
+	return e.execOK(ctx, `AT+CSCS="GSM"`)
+}
+
+// execOK 执行指令并要求返回 OK
+func (e *EC600N) execOK(ctx context.Context, command string) error {
+	result, err := e.Exec(ctx, command, DefaultATTimeout)
+	if err != nil {
+		return err
+	}
+	return result.Err()
+}
+
+// nextSMSRef 生成级联短信参考号
+func (e *EC600N) nextSMSRef() byte {
+	return byte(e.smsRef.Add(1))
+}
@@ -0,0 +1,113 @@
+package ec600n
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultAnnounceRepeat = 1                      // 默认播报次数
+	PlaybackTimeout       = 30 * time.Second       // 单次播报最长等待时间
+	announcePause         = 500 * time.Millisecond // 两次播报之间的停顿
+)
+
+// Speak 通过 TTS（AT+QTTS）播报文本并等待播报结束
+// 文本以 UCS2 编码发送，支持中文
+func (e *EC600N) Speak(ctx context.Context, text string) error {
+	if text == "" {
+		return fmt.Errorf("播报内容不能为空")
+	}
+	return e.playAndWait(ctx, fmt.Sprintf(`AT+QTTS=2,"%s"`, ucs2Hex(text)))
+}
+
+// PlayAudioFile 通过 AT+QPSND 播放模块文件系统中的音频文件并等待播放结束
+func (e *EC600N) PlayAudioFile(ctx context.Context, file string) error {
+	if file == "" {
+		return fmt.Errorf("音频文件不能为空")
+	}
+	return e.playAndWait(ctx, fmt.Sprintf(`AT+QPSND=1,"%s",0`, file))
+}
+
+// StopPlayback 停止正在进行的 TTS 与音频播放，失败时仅记录日志
+func (e *EC600N) StopPlayback() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultATTimeout)
+	defer cancel()
+
+	for _, command := range []string{"AT+QTTS=0", "AT+QPSND=0"} {
+		if err := e.execOK(ctx, command); err != nil {
+			zap.S().Debugf("停止播放失败 [%s]: %v", command, err)
+		}
+	}
+}
+
+// playAndWait 执行播放指令，等待 +QTTS: 0 / +QPSND: 0 上报播放结束，播放开始等其他状态上报忽略
+// 部分固件不上报结束状态，超过 PlaybackTimeout 后视为播放结束
+func (e *EC600N) playAndWait(ctx context.Context, command string) error {
+	// 发送指令前订阅，避免遗漏播放结束事件
+	events, unsubscribe := e.Subscribe(EventPlayback)
+	defer unsubscribe()
+
+	if err := e.execOK(ctx, command); err != nil {
+		return fmt.Errorf("播放失败: %w", err)
+	}
+
+	timer := time.NewTimer(PlaybackTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-events:
+			if playbackFinished(ev) {
+				return nil
+			}
+		case <-timer.C:
+			zap.S().Warnf("等待播放结束超时: %s", command)
+			return nil
+		}
+	}
+}
+
+// playbackFinished 是否为播放结束上报，+QTTS 与 +QPSND 的状态 0 表示播放结束
+func playbackFinished(ev Event) bool {
+	return len(ev.Params) > 0 && ev.Params[0] == "0"
+}
+
+// announce 在通话中按 CallOptions 播报告警内容，ctx 取消时停止播放
+// 设置 AnnounceFile 时播放音频文件，否则以 TTS 播报 Announcement
+func (e *EC600N) announce(ctx context.Context, opts CallOptions) {
+	repeat := opts.AnnounceRepeat
+	if repeat <= 0 {
+		repeat = DefaultAnnounceRepeat
+	}
+
+	for i := 0; i < repeat; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(announcePause):
+			}
+		}
+
+		var err error
+		if opts.AnnounceFile != "" {
+			err = e.PlayAudioFile(ctx, opts.AnnounceFile)
+		} else {
+			err = e.Speak(ctx, opts.Announcement)
+		}
+		if ctx.Err() != nil {
+			// 通话已结束或被取消，停止尚未结束的播放
+			e.StopPlayback()
+			return
+		}
+		if err != nil {
+			zap.S().Errorf("通话中播报失败: %v", err)
+			return
+		}
+	}
+}
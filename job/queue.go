@@ -0,0 +1,502 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/ec600n"
+	"alert-mobile-notify/notification"
+	"alert-mobile-notify/storage"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	QueueFileName   = "jobs.json" // 任务队列文件名
+	QueueMaxHistory = 500         // 最多保留的已结束任务数
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 排队中
+	StatusRunning   Status = "running"   // 执行中
+	StatusDone      Status = "done"      // 已完成
+	StatusFailed    Status = "failed"    // 执行失败
+	StatusCancelled Status = "cancelled" // 已取消
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("任务不存在")
+	// ErrFinished 任务已结束，无法取消
+	ErrFinished = errors.New("任务已结束")
+	// ErrCancelled 任务被取消，作为 Finish 的参数时任务标记为已取消
+	ErrCancelled = errors.New("任务已取消")
+)
+
+// Ack 告警确认信息
+type Ack struct {
+	Number string    `json:"number"` // 确认人号码
+	Method string    `json:"method"` // 确认方式：sms、callback、dtmf
+	At     time.Time `json:"at"`     // 确认时间
+}
+
+// Tier 升级层级
+type Tier struct {
+	Numbers  []string `json:"numbers"`            // 本级通知号码
+	Schedule string   `json:"schedule,omitempty"` // 本级值班表，执行时解析为当前值班人员号码
+	Contacts []string `json:"contacts,omitempty"` // 本级联系人或团队，执行时解析为号码
+	Wait     int      `json:"wait"`               // 本级通知后等待确认的时间（分钟）
+}
+
+// Alert 任务包含的告警，分组窗口内的相关告警合并到同一任务
+type Alert struct {
+	Name     string    `json:"name"`               // 告警名称
+	DedupKey string    `json:"dedupKey,omitempty"` // 去重键
+	At       time.Time `json:"at"`                 // 告警提交时间
+}
+
+// Job 通知任务
+type Job struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`                 // 告警名称
+	PhoneNumbers []string  `json:"phoneNumbers"`         // 通知号码，包含值班表解析出的号码
+	Mode         string    `json:"mode"`                 // 通知方式：call、sms_call、sms
+	Priority     int       `json:"priority"`             // 优先级，数值越大越先执行，相同优先级按提交顺序执行
+	Escalation   string    `json:"escalation,omitempty"` // 升级策略名称
+	Severity     string    `json:"severity,omitempty"`   // 告警级别
+	Alerts       []Alert   `json:"alerts,omitempty"`     // 任务包含的告警，第一条为创建任务的告警
+	GroupKey     string    `json:"groupKey,omitempty"`   // 分组键，相同分组键的告警在分组窗口内合并
+	NotBefore    time.Time `json:"notBefore,omitempty"`  // 分组窗口结束时间，此前任务不会开始执行
+	Client       string    `json:"client,omitempty"`     // 提交任务的 API 客户端 ID
+	Tiers        []Tier    `json:"tiers"`                // 升级层级，未指定策略时为包含全部号码的单一层级
+	Repeat       int       `json:"repeat"`               // 整条链路执行轮数
+	Status       Status    `json:"status"`
+	Error        string    `json:"error,omitempty"` // 失败原因
+	CreatedAt    time.Time `json:"createdAt"`
+	StartedAt    time.Time `json:"startedAt,omitempty"`
+	FinishedAt   time.Time `json:"finishedAt,omitempty"`
+
+	Calls []*ec600n.CallResult `json:"calls,omitempty"` // 每次呼叫的结果，按拨打顺序
+	Ack   *Ack                 `json:"ack,omitempty"`   // 告警确认信息，未确认时为空
+
+	CancelRequested bool `json:"cancelRequested,omitempty"` // 执行中的任务已请求取消，执行方在下次检查时停止
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status != StatusPending && j.Status != StatusRunning
+}
+
+// Title 返回任务包含的全部告警名称，以顿号分隔
+func (j *Job) Title() string {
+	if len(j.Alerts) <= 1 {
+		return j.Name
+	}
+	names := make([]string, 0, len(j.Alerts))
+	for _, a := range j.Alerts {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, "、")
+}
+
+// clone 返回任务副本，避免调用方与队列共享切片
+func (j *Job) clone() *Job {
+	copied := *j
+	copied.PhoneNumbers = append([]string(nil), j.PhoneNumbers...)
+	copied.Tiers = append([]Tier(nil), j.Tiers...)
+	copied.Calls = append([]*ec600n.CallResult(nil), j.Calls...)
+	copied.Alerts = append([]Alert(nil), j.Alerts...)
+	return &copied
+}
+
+// Queue 持久化的通知任务队列
+// 任务按优先级与提交顺序依次执行，队列变化时写入本地文件，服务重启后继续执行未完成的任务
+type Queue struct {
+	mu     sync.Mutex
+	file   *storage.JSONFile
+	jobs   []*Job
+	wakeup chan struct{}
+}
+
+// NewQueue 创建任务队列，file 为 nil 时仅保存在内存中
+// 服务异常退出时处于执行中的任务会重新排队
+func NewQueue(file *storage.JSONFile) (*Queue, error) {
+	q := &Queue{file: file, wakeup: make(chan struct{}, 1)}
+	if file == nil {
+		return q, nil
+	}
+
+	if _, err := file.Load(&q.jobs); err != nil {
+		return nil, fmt.Errorf("加载任务队列失败: %w", err)
+	}
+
+	pending := 0
+	for _, j := range q.jobs {
+		if j.Status == StatusRunning && j.CancelRequested {
+			j.Status = StatusCancelled
+			j.FinishedAt = time.Now()
+			continue
+		}
+		if j.Status == StatusRunning {
+			zap.S().Warnf("任务在服务停止时未执行完成，重新排队: id=%s, name=%s", j.ID, j.Name)
+			j.Status = StatusPending
+			j.StartedAt = time.Time{}
+		}
+		if j.Status == StatusPending {
+			pending++
+		}
+	}
+	if pending > 0 {
+		zap.S().Infof("恢复未完成的任务: %d 个", pending)
+		q.signal()
+	}
+	return q, nil
+}
+
+// Enqueue 提交任务并分配 ID，返回提交后排在该任务前面的任务数
+func (q *Queue) Enqueue(j *Job) (int, error) {
+	id, err := newJobID()
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j.ID = id
+	j.Status = StatusPending
+	j.CreatedAt = time.Now()
+
+	ahead := 0
+	for _, other := range q.jobs {
+		if other.Status == StatusRunning || (other.Status == StatusPending && other.Priority >= j.Priority) {
+			ahead++
+		}
+	}
+
+	q.jobs = append(q.jobs, j)
+	if err := q.save(); err != nil {
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		return 0, err
+	}
+
+	q.signal()
+	return ahead, nil
+}
+
+// Next 阻塞等待下一个待执行的任务并标记为执行中，ctx 取消时返回错误
+// 处于分组窗口内的任务在窗口结束后才会返回
+func (q *Queue) Next(ctx context.Context) (*Job, error) {
+	for {
+		j, wait := q.take(time.Now())
+		if j != nil {
+			return j, nil
+		}
+
+		if err := q.wait(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait 等待队列变化、等待时间到达或 ctx 取消，wait 为 0 时不限时
+func (q *Queue) wait(ctx context.Context, wait time.Duration) error {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.wakeup:
+	case <-timeout:
+	}
+	return nil
+}
+
+// Finish 标记任务结束，err 为 ErrCancelled 时标记为已取消，其他错误标记为失败
+func (q *Queue) Finish(id string, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := q.find(id)
+	if j == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	switch {
+	case err == nil:
+		j.Status = StatusDone
+	case errors.Is(err, ErrCancelled):
+		j.Status = StatusCancelled
+	default:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+	}
+	j.FinishedAt = time.Now()
+	q.trim()
+	return q.save()
+}
+
+// Cancel 取消任务并返回取消前的任务副本
+// 排队中的任务直接标记为已取消；执行中的任务记录取消请求，由执行方检查 CancelRequested 停止后以 ErrCancelled 结束
+func (q *Queue) Cancel(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := q.find(id)
+	if j == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if j.Finished() {
+		return nil, fmt.Errorf("%w: %s", ErrFinished, id)
+	}
+
+	copied := j.clone()
+	if j.Status == StatusPending {
+		j.Status = StatusCancelled
+		j.FinishedAt = time.Now()
+	} else {
+		j.CancelRequested = true
+	}
+	if err := q.save(); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// CancelRequested 执行中的任务是否已请求取消
+func (q *Queue) CancelRequested(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := q.find(id)
+	return j != nil && j.CancelRequested
+}
+
+// AddCall 记录任务中一次呼叫的结果
+func (q *Queue) AddCall(id string, result *ec600n.CallResult) error {
+	return q.update(id, func(j *Job) {
+		j.Calls = append(j.Calls, result)
+	})
+}
+
+// SetPhoneNumbers 记录任务执行时实际通知的号码
+func (q *Queue) SetPhoneNumbers(id string, numbers []string) error {
+	return q.update(id, func(j *Job) {
+		j.PhoneNumbers = numbers
+	})
+}
+
+// SetAck 记录任务的告警确认信息
+func (q *Queue) SetAck(id string, ack *Ack) error {
+	return q.update(id, func(j *Job) {
+		j.Ack = ack
+	})
+}
+
+// Get 返回任务副本，任务不存在时返回 nil
+func (q *Queue) Get(id string) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := q.find(id)
+	if j == nil {
+		return nil
+	}
+	return j.clone()
+}
+
+// Pending 返回排队中的任务数
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, j := range q.jobs {
+		if j.Status == StatusPending {
+			n++
+		}
+	}
+	return n
+}
+
+// FindDuplicate 返回包含相同去重键告警的任务副本，不存在时返回 nil
+// 未结束的任务，以及 since 之后创建且已完成的任务视为重复；已取消与执行失败的任务不影响告警重新提交
+func (q *Queue) FindDuplicate(dedupKey string, since time.Time) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, j := range q.jobs {
+		if j.Status == StatusCancelled || j.Status == StatusFailed || (j.Finished() && !j.CreatedAt.After(since)) {
+			continue
+		}
+		for _, a := range j.Alerts {
+			if a.DedupKey == dedupKey {
+				return j.clone()
+			}
+		}
+	}
+	return nil
+}
+
+// Merge 将告警合并到分组键相同且分组窗口未结束的排队任务，返回合并后的任务副本
+// 合并的告警优先级或级别更高时提升任务的优先级与级别；不存在可合并的任务时返回 nil
+func (q *Queue) Merge(groupKey string, alert Alert, priority int, severity string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, j := range q.jobs {
+		if j.Status != StatusPending || j.GroupKey != groupKey || !alert.At.Before(j.NotBefore) {
+			continue
+		}
+		prevPriority, prevSeverity := j.Priority, j.Severity
+		j.Alerts = append(j.Alerts, alert)
+		j.Priority = max(j.Priority, priority)
+		if notification.SeverityRank(severity) > notification.SeverityRank(j.Severity) {
+			j.Severity = severity
+		}
+		if err := q.save(); err != nil {
+			j.Alerts = j.Alerts[:len(j.Alerts)-1]
+			j.Priority, j.Severity = prevPriority, prevSeverity
+			return nil, err
+		}
+		return j.clone(), nil
+	}
+	return nil, nil
+}
+
+// take 取出优先级最高、提交最早的待执行任务并标记为执行中
+// 没有可执行的任务时返回最近的分组窗口结束前需等待的时间，没有排队任务时为 0
+func (q *Queue) take(now time.Time) (*Job, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var next *Job
+	var wait time.Duration
+	for _, j := range q.jobs {
+		if j.Status != StatusPending {
+			continue
+		}
+		if j.NotBefore.After(now) {
+			if d := j.NotBefore.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if next == nil || j.Priority > next.Priority {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, wait
+	}
+
+	next.Status = StatusRunning
+	next.StartedAt = time.Now()
+	if err := q.save(); err != nil {
+		zap.S().Errorf("保存任务队列失败: %v", err)
+	}
+
+	return next.clone(), 0
+}
+
+// update 修改任务并持久化
+func (q *Queue) update(id string, fn func(j *Job)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	j := q.find(id)
+	if j == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	fn(j)
+	return q.save()
+}
+
+// find 按 ID 查找任务，调用方需持有锁
+func (q *Queue) find(id string) *Job {
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// trim 移除超出保留数量的最早的已结束任务，调用方需持有锁
+func (q *Queue) trim() {
+	finished := 0
+	for _, j := range q.jobs {
+		if j.Finished() {
+			finished++
+		}
+	}
+	if finished <= QueueMaxHistory {
+		return
+	}
+
+	drop := finished - QueueMaxHistory
+	kept := q.jobs[:0]
+	for _, j := range q.jobs {
+		if drop > 0 && j.Finished() {
+			drop--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	q.jobs = kept
+}
+
+// save 持久化任务队列，调用方需持有锁
+func (q *Queue) save() error {
+	if q.file == nil {
+		return nil
+	}
+	return q.file.Save(q.jobs)
+}
+
+// signal 唤醒等待任务的 Next
+func (q *Queue) signal() {
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// newJobID 生成随机任务 ID
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成任务 ID 失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewQueueFromConfig 在配置的数据目录下创建持久化任务队列
+func NewQueueFromConfig(cfg *config.Config) (*Queue, error) {
+	file, err := storage.NewJSONFile(storage.DataDir(cfg), QueueFileName)
+	if err != nil {
+		return nil, err
+	}
+	return NewQueue(file)
+}
+
+// ProvideQueue 提供任务队列依赖注入
+func ProvideQueue() fx.Option {
+	return fx.Provide(NewQueueFromConfig)
+}
@@ -0,0 +1,173 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"alert-mobile-notify/ec600n"
+	"alert-mobile-notify/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_Order(t *testing.T) {
+	q, err := NewQueue(nil)
+	require.NoError(t, err)
+
+	first := &Job{Name: "first"}
+	second := &Job{Name: "second"}
+	urgent := &Job{Name: "urgent", Priority: 10}
+
+	ahead, err := q.Enqueue(first)
+	require.NoError(t, err)
+	assert.Equal(t, 0, ahead)
+	ahead, err = q.Enqueue(second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ahead)
+	ahead, err = q.Enqueue(urgent)
+	require.NoError(t, err)
+	assert.Equal(t, 0, ahead)
+	assert.NotEmpty(t, first.ID)
+	assert.Equal(t, 3, q.Pending())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		j, err := q.Next(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRunning, j.Status)
+		names = append(names, j.Name)
+		require.NoError(t, q.Finish(j.ID, nil))
+	}
+	assert.Equal(t, []string{"urgent", "first", "second"}, names)
+	assert.Equal(t, StatusDone, q.Get(first.ID).Status)
+
+	// 队列为空时阻塞直到 ctx 取消
+	short, cancelShort := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShort()
+	_, err = q.Next(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueue_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	file, err := storage.NewJSONFile(dir, QueueFileName)
+	require.NoError(t, err)
+
+	q, err := NewQueue(file)
+	require.NoError(t, err)
+	running := &Job{Name: "running", PhoneNumbers: []string{"13800138000"}}
+	pending := &Job{Name: "pending"}
+	failed := &Job{Name: "failed"}
+	for _, j := range []*Job{failed, running, pending} {
+		_, err := q.Enqueue(j)
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	j, err := q.Next(ctx)
+	require.NoError(t, err)
+	require.NoError(t, q.Finish(j.ID, errors.New("模块未连接")))
+	_, err = q.Next(ctx)
+	require.NoError(t, err)
+
+	// 模拟重启：执行中的任务重新排队，已结束的任务保留结果
+	reloaded, err := NewQueue(file)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reloaded.Pending())
+	assert.Equal(t, StatusFailed, reloaded.Get(failed.ID).Status)
+	assert.Equal(t, "模块未连接", reloaded.Get(failed.ID).Error)
+
+	j, err = reloaded.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, running.ID, j.ID)
+	assert.Equal(t, []string{"13800138000"}, j.PhoneNumbers)
+	assert.Nil(t, reloaded.Get("missing"))
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	q, err := NewQueue(nil)
+	require.NoError(t, err)
+
+	running := &Job{Name: "running"}
+	pending := &Job{Name: "pending"}
+	for _, j := range []*Job{running, pending} {
+		_, err := q.Enqueue(j)
+		require.NoError(t, err)
+	}
+	_, err = q.Next(context.Background())
+	require.NoError(t, err)
+
+	// 执行中的任务由执行方以 ErrCancelled 结束
+	j, err := q.Cancel(running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, j.Status)
+	assert.True(t, q.CancelRequested(running.ID))
+	require.NoError(t, q.AddCall(running.ID, &ec600n.CallResult{Number: "13800138000", EndReason: ec600n.EndReasonCancelled}))
+	require.NoError(t, q.Finish(running.ID, ErrCancelled))
+	assert.Equal(t, StatusCancelled, q.Get(running.ID).Status)
+	assert.Len(t, q.Get(running.ID).Calls, 1)
+
+	_, err = q.Cancel(pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, q.Get(pending.ID).Status)
+	assert.Equal(t, 0, q.Pending())
+
+	_, err = q.Cancel(pending.ID)
+	assert.ErrorIs(t, err, ErrFinished)
+	_, err = q.Cancel("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestQueue_MergeAndDedup(t *testing.T) {
+	q, err := NewQueue(nil)
+	require.NoError(t, err)
+	now := time.Now()
+
+	first := &Job{
+		Name:      "disk",
+		Alerts:    []Alert{{Name: "disk", DedupKey: "disk", At: now}},
+		GroupKey:  "ops",
+		NotBefore: now.Add(100 * time.Millisecond),
+	}
+	_, err = q.Enqueue(first)
+	require.NoError(t, err)
+
+	// 分组窗口内相同分组键的告警合并，其他分组不合并
+	merged, err := q.Merge("ops", Alert{Name: "cpu", DedupKey: "cpu", At: now.Add(10 * time.Millisecond)}, 0, "")
+	require.NoError(t, err)
+	require.NotNil(t, merged)
+	assert.Equal(t, first.ID, merged.ID)
+	assert.Equal(t, "disk、cpu", merged.Title())
+	merged, err = q.Merge("dba", Alert{Name: "mysql", At: now}, 0, "")
+	require.NoError(t, err)
+	assert.Nil(t, merged)
+
+	assert.Equal(t, first.ID, q.FindDuplicate("cpu", now).ID)
+	assert.Nil(t, q.FindDuplicate("mem", now))
+
+	// 分组窗口结束前不执行
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	j, err := q.Next(ctx)
+	require.NoError(t, err)
+	assert.False(t, time.Now().Before(first.NotBefore))
+	assert.Len(t, j.Alerts, 2)
+
+	// 任务结束后去重窗口内仍视为重复
+	require.NoError(t, q.Finish(j.ID, nil))
+	assert.NotNil(t, q.FindDuplicate("disk", now.Add(-time.Minute)))
+	assert.Nil(t, q.FindDuplicate("disk", time.Now()))
+
+	// 执行失败的任务不视为重复，监控系统重试时重新通知
+	failed := &Job{Name: "load", Alerts: []Alert{{Name: "load", DedupKey: "load", At: now}}}
+	_, err = q.Enqueue(failed)
+	require.NoError(t, err)
+	require.NoError(t, q.Finish(failed.ID, errors.New("EC600N 模块未连接")))
+	assert.Nil(t, q.FindDuplicate("load", now.Add(-time.Minute)))
+}
@@ -0,0 +1,142 @@
+// Package jsonpath 实现 JSONPath 的常用子集，用于从任意 JSON 请求体中提取字段
+//
+// 支持的语法：$ 根节点、.name 与 ['name'] 成员、[n] 数组下标（负数从末尾计数）、.* 与 [*] 通配
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment 路径中的一段，wildcard 为 true 时匹配全部成员或元素
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Path 解析后的 JSONPath
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+// Compile 解析 JSONPath 表达式
+func Compile(expr string) (*Path, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return nil, fmt.Errorf("JSONPath 必须以 $ 开头: %s", expr)
+	}
+
+	p := &Path{expr: expr}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath 成员名为空: %s", expr)
+			}
+			if name == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath 缺少 ]: %s", expr)
+			}
+			seg, err := parseBracket(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath 格式错误 [%s]: %w", expr, err)
+			}
+			p.segments = append(p.segments, seg)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath 格式错误: %s", expr)
+		}
+	}
+	return p, nil
+}
+
+// parseBracket 解析 [] 中的内容：*、下标或带引号的成员名
+func parseBracket(s string) (segment, error) {
+	if s == "*" {
+		return segment{wildcard: true}, nil
+	}
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return segment{key: s[1 : len(s)-1]}, nil
+	}
+	index, err := strconv.Atoi(s)
+	if err != nil {
+		return segment{}, fmt.Errorf("无效的下标 %q", s)
+	}
+	return segment{index: index, isIndex: true}, nil
+}
+
+// String 返回原始表达式
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find 返回 data 中所有匹配的值，data 为 json.Unmarshal 到 any 的结果
+// 成员或下标不存在时跳过，不视为错误
+func (p *Path) Find(data any) []any {
+	nodes := []any{data}
+	for _, seg := range p.segments {
+		var next []any
+		for _, node := range nodes {
+			next = append(next, seg.apply(node)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// apply 在单个节点上应用路径段
+func (s segment) apply(node any) []any {
+	switch v := node.(type) {
+	case map[string]any:
+		if s.wildcard {
+			out := make([]any, 0, len(v))
+			for _, child := range v {
+				out = append(out, child)
+			}
+			return out
+		}
+		if child, ok := v[s.key]; ok && !s.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if s.wildcard {
+			return v
+		}
+		if s.isIndex {
+			i := s.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				return []any{v[i]}
+			}
+		}
+	}
+	return nil
+}
+
+// Find 解析表达式并返回 data 中所有匹配的值
+func Find(data any, expr string) ([]any, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(data), nil
+}
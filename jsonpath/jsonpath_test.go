@@ -0,0 +1,44 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	var data any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"monitor": {"name": "api", "url": "https://example.com"},
+		"owners": [{"id": "zhangsan"}, {"id": "lisi"}],
+		"tags": {"team": "dba"},
+		"level": 2
+	}`), &data))
+
+	cases := []struct {
+		expr string
+		want []any
+	}{
+		{"$.monitor.name", []any{"api"}},
+		{"$['monitor']['url']", []any{"https://example.com"}},
+		{"$.owners[0].id", []any{"zhangsan"}},
+		{"$.owners[-1].id", []any{"lisi"}},
+		{"$.owners[*].id", []any{"zhangsan", "lisi"}},
+		{"$.tags.*", []any{"dba"}},
+		{"$.level", []any{float64(2)}},
+		{"$.missing.name", nil},
+		{"$.owners[5]", nil},
+	}
+	for _, c := range cases {
+		got, err := Find(data, c.expr)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.want, got, c.expr)
+	}
+
+	for _, expr := range []string{"monitor.name", "$.owners[x]", "$.owners[0", "$..name"} {
+		_, err := Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
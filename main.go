@@ -13,8 +13,11 @@ import (
 
 	"alert-mobile-notify/api"
 	"alert-mobile-notify/config"
+	"alert-mobile-notify/contact"
 	"alert-mobile-notify/ec600n"
+	"alert-mobile-notify/job"
 	"alert-mobile-notify/notification"
+	"alert-mobile-notify/oncall"
 	"github.com/robfig/cron/v3"
 	"go.uber.org/fx"
 )
@@ -30,9 +33,14 @@ func main() {
 		// 配置模块
 		config.ProvideConfig(),
 		// 通知模块
-		notification.ProvideWechatNotify(),
+		notification.ProvideNotifier(),
 		// EC600N模块
 		ec600n.ProvideEC600N(),
+		// 通知任务队列
+		job.ProvideQueue(),
+		// 值班表与通讯录
+		oncall.ProvideSchedules(),
+		contact.ProvideDirectory(),
 		// HTTP API服务器模块
 		api.ProvideHTTPServer(),
 		// 启动调度器
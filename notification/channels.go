@@ -0,0 +1,237 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPI Telegram Bot API 默认地址
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// newHTTPClient 创建通知渠道使用的 HTTP 客户端
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: HTTPRequestTimeout}
+}
+
+// postJSON 以 JSON 格式 POST payload，响应状态码非 2xx 时返回错误
+// result 不为 nil 时将响应体解析到 result
+func postJSON(client *http.Client, target string, payload any, headers map[string]string, result any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentTypeJSON)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送 webhook 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取 webhook 响应失败: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回错误状态码: %d, 响应内容: %s", resp.StatusCode, string(body))
+	}
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("解析 webhook 响应失败: %w, 响应内容: %s", err, string(body))
+		}
+	}
+	return nil
+}
+
+// hmacBase64 计算 HMAC-SHA256 并以 base64 编码
+func hmacBase64(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// DingTalkNotify 钉钉群机器人
+type DingTalkNotify struct {
+	name       string
+	webhookURL string
+	secret     string // 加签密钥，为空时不签名
+	client     *http.Client
+}
+
+// dingTalkSign 计算钉钉加签：以密钥对 "timestamp\nsecret" 计算 HMAC-SHA256 后 base64 编码
+func dingTalkSign(secret, timestamp string) string {
+	return hmacBase64(secret, timestamp+"\n"+secret)
+}
+
+// Name 返回渠道名称
+func (d *DingTalkNotify) Name() string {
+	return d.name
+}
+
+// Send 发送文本消息到钉钉群，配置密钥时在地址上附加 timestamp 与 sign
+func (d *DingTalkNotify) Send(msg *Message) error {
+	target := d.webhookURL
+	if d.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		query := url.Values{"timestamp": {timestamp}, "sign": {dingTalkSign(d.secret, timestamp)}}
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + query.Encode()
+	}
+
+	payload := map[string]any{
+		"msgtype": "text",
+		"text":    map[string]any{"content": msg.Text()},
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := postJSON(d.client, target, payload, nil, &result); err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("钉钉返回错误: errcode=%d, errmsg=%s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// FeishuNotify 飞书群机器人
+type FeishuNotify struct {
+	name       string
+	webhookURL string
+	secret     string // 加签密钥，为空时不签名
+	client     *http.Client
+}
+
+// feishuSign 计算飞书加签：以 "timestamp\nsecret" 为密钥对空串计算 HMAC-SHA256 后 base64 编码
+func feishuSign(secret, timestamp string) string {
+	return hmacBase64(timestamp+"\n"+secret, "")
+}
+
+// Name 返回渠道名称
+func (f *FeishuNotify) Name() string {
+	return f.name
+}
+
+// Send 发送文本消息到飞书群，配置密钥时在请求体中附加 timestamp 与 sign
+func (f *FeishuNotify) Send(msg *Message) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]any{"text": msg.Text()},
+	}
+	if f.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = feishuSign(f.secret, timestamp)
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(f.client, f.webhookURL, payload, nil, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("飞书返回错误: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// SlackNotify Slack incoming webhook
+type SlackNotify struct {
+	name       string
+	webhookURL string
+	client     *http.Client
+}
+
+// Name 返回渠道名称
+func (s *SlackNotify) Name() string {
+	return s.name
+}
+
+// Send 发送文本消息到 Slack 频道
+func (s *SlackNotify) Send(msg *Message) error {
+	return postJSON(s.client, s.webhookURL, map[string]any{"text": msg.Text()}, nil, nil)
+}
+
+// TelegramNotify Telegram 机器人
+type TelegramNotify struct {
+	name   string
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// Name 返回渠道名称
+func (t *TelegramNotify) Name() string {
+	return t.name
+}
+
+// Send 通过 sendMessage 发送文本消息到 Telegram 会话
+func (t *TelegramNotify) Send(msg *Message) error {
+	target := strings.TrimRight(t.apiURL, "/") + "/bot" + t.token + "/sendMessage"
+	payload := map[string]any{"chat_id": t.chatID, "text": msg.Text()}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := postJSON(t.client, target, payload, nil, &result); err != nil {
+		// 请求地址中包含 token，不能出现在日志中
+		return errors.New(strings.ReplaceAll(err.Error(), t.token, "***"))
+	}
+	if !result.OK {
+		return fmt.Errorf("Telegram 返回错误: %s", result.Description)
+	}
+	return nil
+}
+
+// WebhookNotify 通用 JSON webhook
+// 请求体为 {"channel": 渠道名称, "event": 事件类型, "title": 标题, "content": 消息内容, "mentions": 企业微信用户 ID, "time": RFC3339 时间}
+type WebhookNotify struct {
+	name       string
+	webhookURL string
+	headers    map[string]string
+	client     *http.Client
+}
+
+// Name 返回渠道名称
+func (w *WebhookNotify) Name() string {
+	return w.name
+}
+
+// Send 发送消息到 webhook，响应状态码为 2xx 视为成功
+func (w *WebhookNotify) Send(msg *Message) error {
+	payload := map[string]any{
+		"channel":  w.name,
+		"event":    msg.Event,
+		"title":    msg.Title,
+		"content":  msg.Text(),
+		"mentions": msg.Mentions,
+		"time":     time.Now().Format(time.RFC3339),
+	}
+	return postJSON(w.client, w.webhookURL, payload, w.headers, nil)
+}
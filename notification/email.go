@@ -0,0 +1,257 @@
+package notification
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-mobile-notify/config"
+)
+
+const (
+	// ChannelEmail SMTP 邮件
+	ChannelEmail = "email"
+
+	// SMTPSecuritySTARTTLS 明文连接后通过 STARTTLS 升级为 TLS
+	SMTPSecuritySTARTTLS = "starttls"
+	// SMTPSecurityTLS 隐式 TLS（SMTPS）
+	SMTPSecurityTLS = "tls"
+	// SMTPSecurityNone 不加密，仅用于内网中继
+	SMTPSecurityNone = "none"
+
+	// DefaultRecipients 未单独配置收件人的事件使用的收件人键
+	DefaultRecipients = "default"
+)
+
+// emailHTML 邮件 HTML 正文模板
+var emailHTML = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; font-size: 14px;">
+<h3>{{.Title}}</h3>
+{{- if .Fields}}
+<table cellpadding="6" cellspacing="0" style="border-collapse: collapse;">
+{{- range .Fields}}
+<tr><th align="left" style="border: 1px solid #ddd; background: #f5f5f5;">{{.Name}}</th><td style="border: 1px solid #ddd; white-space: pre-wrap;">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<pre>{{.Content}}</pre>
+{{- end}}
+{{- if .Note}}
+<p>{{.Note}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// EmailNotify SMTP 邮件通知渠道
+type EmailNotify struct {
+	name       string
+	host       string
+	port       int
+	security   string
+	username   string
+	password   string
+	from       string
+	recipients map[string][]string // 事件类型对应的收件人
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+}
+
+// newEmailNotify 根据渠道配置创建邮件通知渠道
+func newEmailNotify(name string, c config.Notifier) (*EmailNotify, error) {
+	if c.SMTP.Host == "" {
+		return nil, fmt.Errorf("渠道 %s 未配置 smtp.host", name)
+	}
+	if len(c.Recipients) == 0 {
+		return nil, fmt.Errorf("渠道 %s 未配置 recipients", name)
+	}
+
+	e := &EmailNotify{
+		name:       name,
+		host:       c.SMTP.Host,
+		port:       c.SMTP.Port,
+		security:   strings.ToLower(c.SMTP.Security),
+		username:   c.SMTP.Username,
+		password:   c.SMTP.Password,
+		from:       c.SMTP.From,
+		recipients: c.Recipients,
+		tlsConfig:  &tls.Config{ServerName: c.SMTP.Host},
+		timeout:    HTTPRequestTimeout,
+	}
+	if e.security == "" {
+		e.security = SMTPSecuritySTARTTLS
+	}
+	if e.port == 0 {
+		switch e.security {
+		case SMTPSecuritySTARTTLS:
+			e.port = 587
+		case SMTPSecurityTLS:
+			e.port = 465
+		case SMTPSecurityNone:
+			e.port = 25
+		}
+	}
+	switch e.security {
+	case SMTPSecuritySTARTTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("渠道 %s 不支持的加密方式: %s", name, c.SMTP.Security)
+	}
+	if e.from == "" {
+		e.from = e.username
+	}
+	if e.from == "" {
+		return nil, fmt.Errorf("渠道 %s 未配置 smtp.from", name)
+	}
+	return e, nil
+}
+
+// Name 返回渠道名称
+func (e *EmailNotify) Name() string {
+	return e.name
+}
+
+// recipientsFor 返回事件的收件人，未单独配置时使用 default 收件人
+func (e *EmailNotify) recipientsFor(event string) []string {
+	if to, ok := e.recipients[event]; ok {
+		return to
+	}
+	return e.recipients[DefaultRecipients]
+}
+
+// Send 发送邮件，正文包含纯文本与 HTML 两种格式；事件没有收件人时不发送
+func (e *EmailNotify) Send(msg *Message) error {
+	to := e.recipientsFor(msg.Event)
+	if len(to) == 0 {
+		return nil
+	}
+	data, err := e.buildMail(msg, to)
+	if err != nil {
+		return err
+	}
+	return e.sendMail(to, data)
+}
+
+// buildMail 构造 multipart/alternative 邮件
+func (e *EmailNotify) buildMail(msg *Message, to []string) ([]byte, error) {
+	text := msg.Text()
+	subject := msg.subject()
+
+	var html bytes.Buffer
+	if err := emailHTML.Execute(&html, msg); err != nil {
+		return nil, fmt.Errorf("生成邮件正文失败: %w", err)
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", []byte(text)},
+		{"text/html; charset=UTF-8", html.Bytes()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("生成邮件正文失败: %w", err)
+		}
+		writeBase64Lines(w, part.content)
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("生成邮件正文失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", e.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines 以每行 76 个字符写入 base64 编码内容
+func writeBase64Lines(w io.Writer, content []byte) {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// sendMail 连接 SMTP 服务器并投递邮件
+func (e *EmailNotify) sendMail(to []string, data []byte) error {
+	addr := net.JoinHostPort(e.host, strconv.Itoa(e.port))
+	dialer := &net.Dialer{Timeout: e.timeout}
+
+	var conn net.Conn
+	var err error
+	if e.security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, e.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(e.timeout))
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("连接 SMTP 服务器失败: %w", err)
+	}
+	defer client.Close()
+
+	if e.security == SMTPSecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(e.tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.from); err != nil {
+		return fmt.Errorf("设置发件人失败: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return client.Quit()
+}
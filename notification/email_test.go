@@ -0,0 +1,197 @@
+package notification
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTP 本地 SMTP 服务器，记录收到的邮件
+type fakeSMTP struct {
+	listener net.Listener
+	tls      *tls.Config // 不为 nil 时支持 STARTTLS
+	rcpts    chan []string
+	data     chan string
+	auth     chan string
+}
+
+func newFakeSMTP(t *testing.T, tlsConfig *tls.Config) *fakeSMTP {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTP{listener: l, tls: tlsConfig, rcpts: make(chan []string, 1), data: make(chan string, 1), auth: make(chan string, 1)}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+
+	var rcpts []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		switch verb := strings.ToUpper(strings.Fields(cmd + " x")[0]); verb {
+		case "EHLO":
+			if s.tls != nil {
+				reply("250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				reply("250-fake\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r = tlsConn, bufio.NewReader(tlsConn)
+		case "AUTH":
+			s.auth <- cmd
+			reply("235 ok")
+		case "MAIL":
+			reply("250 ok")
+		case "RCPT":
+			rcpts = append(rcpts, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.rcpts <- rcpts
+			s.data <- data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+// mailBodies 解析邮件，返回主题与各部分正文（按 Content-Type）
+func mailBodies(t *testing.T, data string) (string, map[string]string) {
+	m, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	require.NoError(t, err)
+
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		raw, err := io.ReadAll(part)
+		require.NoError(t, err)
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\r\n", ""))
+		require.NoError(t, err)
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[mediaType] = string(decoded)
+	}
+	return subject, bodies
+}
+
+func TestEmailNotify(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	defer ts.Close()
+	server := newFakeSMTP(t, ts.TLS)
+
+	cfg := &config.Config{Notifiers: []config.Notifier{{
+		Type: ChannelEmail,
+		SMTP: config.SMTP{Host: "127.0.0.1", Port: server.port(), Username: "alert@example.com", Password: "pass"},
+		Recipients: map[string][]string{
+			DefaultRecipients: {"ops@example.com"},
+			EventNetwork:      {"noc@example.com", "ops@example.com"},
+			EventSMS:          {},
+		},
+	}}}
+	registry, err := NewRegistry(cfg)
+	require.NoError(t, err)
+	email := registry.Channels()[0].(*EmailNotify)
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	email.tlsConfig.RootCAs = roots
+
+	// 未配置收件人的事件不发送
+	require.NoError(t, email.Send(&Message{Event: EventSMS, Title: "📩 收到短信"}))
+
+	msg := &Message{
+		Event: EventNetwork,
+		Title: "EC600N 网络状态报告",
+		Fields: []Field{
+			{Name: "状态", Value: "异常"},
+			{Name: "运营商", Value: "<CMCC>"},
+		},
+	}
+	require.NoError(t, email.Send(msg))
+	assert.Contains(t, <-server.auth, "AUTH PLAIN")
+	assert.Equal(t, []string{"noc@example.com", "ops@example.com"}, <-server.rcpts)
+
+	subject, bodies := mailBodies(t, <-server.data)
+	assert.Equal(t, "EC600N 网络状态报告", subject)
+	assert.Equal(t, "EC600N 网络状态报告\n状态: 异常\n运营商: <CMCC>", bodies["text/plain"])
+	assert.Contains(t, bodies["text/html"], "<th align=\"left\" style=\"border: 1px solid #ddd; background: #f5f5f5;\">运营商</th>")
+	assert.Contains(t, bodies["text/html"], "&lt;CMCC&gt;")
+}
+
+func TestEmailNotify_Config(t *testing.T) {
+	cases := []config.Notifier{
+		{Type: ChannelEmail, Recipients: map[string][]string{DefaultRecipients: {"ops@example.com"}}},
+		{Type: ChannelEmail, SMTP: config.SMTP{Host: "smtp.example.com", From: "a@example.com"}},
+		{Type: ChannelEmail, SMTP: config.SMTP{Host: "smtp.example.com", From: "a@example.com", Security: "ssl"}, Recipients: map[string][]string{DefaultRecipients: {"ops@example.com"}}},
+	}
+	for i, c := range cases {
+		_, err := newEmailNotify(strconv.Itoa(i), c)
+		assert.Error(t, err, i)
+	}
+
+	e, err := newEmailNotify("email", config.Notifier{
+		SMTP:       config.SMTP{Host: "smtp.example.com", Security: "TLS", Username: "alert@example.com"},
+		Recipients: map[string][]string{DefaultRecipients: {"ops@example.com"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 465, e.port)
+	assert.Equal(t, "alert@example.com", e.from)
+}
@@ -9,9 +9,8 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"sync"
 	"time"
-
-	"go.uber.org/fx"
 )
 
 const (
@@ -19,6 +18,10 @@ const (
 	HTTPRequestTimeout = 10 * time.Second
 	// WechatMsgTypeText 企业微信文本消息类型
 	WechatMsgTypeText = "text"
+	// WechatMsgTypeMarkdown 企业微信 markdown 消息类型
+	WechatMsgTypeMarkdown = "markdown"
+	// WechatMsgTypeTemplateCard 企业微信模板卡片消息类型
+	WechatMsgTypeTemplateCard = "template_card"
 	// ContentTypeJSON JSON 内容类型
 	ContentTypeJSON = "application/json"
 )
@@ -27,11 +30,29 @@ const (
 type WechatNotify struct {
 	config     *config.Config
 	client     *http.Client
+	name       string
 	webhookURL string
+	msgType    string // 消息类型：text、markdown、template_card
+
+	// 发送队列：消息由后台协程合并、限流后发送，调用方不等待发送完成
+	mu          sync.Mutex
+	queue       []*wechatPost
+	wakeup      chan struct{}
+	bucket      *tokenBucket  // 机器人发送频率限制
+	mergeWindow time.Duration // 开始发送前等待合并的时间
+	startOnce   sync.Once
+	stop        func()
+	stopped     bool                          // 发送协程已停止，之后加入的消息直接按发送失败处理
+	onFailure   func(msg *Message, err error) // 后台发送失败时调用，未设置时只记录日志
 }
 
 // NewWechatNotify 创建新的企业微信通知器
 func NewWechatNotify(cfg *config.Config) *WechatNotify {
+	return newWechatNotify(cfg, ChannelWecom, cfg.Wechat.WebhookURL, cfg.Wechat.MsgType)
+}
+
+// newWechatNotify 创建发送到 webhookURL 的企业微信通知器
+func newWechatNotify(cfg *config.Config, name, webhookURL, msgType string) *WechatNotify {
 	// 创建 HTTP 客户端，配置 TLS（生产环境建议使用有效的证书）
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{
@@ -45,29 +66,74 @@ func NewWechatNotify(cfg *config.Config) *WechatNotify {
 			Transport: transport,
 			Timeout:   HTTPRequestTimeout,
 		},
-		webhookURL: cfg.Wechat.WebhookURL,
+		name:        name,
+		webhookURL:  webhookURL,
+		msgType:     msgType,
+		wakeup:      make(chan struct{}, 1),
+		bucket:      newTokenBucket(wechatRateLimit(cfg), time.Minute),
+		mergeWindow: time.Duration(cfg.Wechat.MergeWindow) * time.Second,
 	}
 }
 
-// SendToWechat 发送消息到企业微信
-func (w *WechatNotify) SendToWechat(message string) error {
-	// 始终记录日志
-	zap.S().Infof("[通知] %s", message)
+// Name 返回渠道名称
+func (w *WechatNotify) Name() string {
+	return w.name
+}
 
-	// 如果未配置 webhook URL，只记录日志
-	if w.webhookURL == "" {
-		zap.S().Error("未配置 webhook URL，仅记录日志")
-		return nil
+// Send 将消息加入发送队列后立即返回，由后台协程按配置的消息类型发送，
+// 发送失败时交给 onFailure 处理（加入发件箱）
+func (w *WechatNotify) Send(msg *Message) error {
+	w.enqueue(newWechatSend(msg, nil), w.postsFor(msg))
+	return nil
+}
+
+// sendSync 发送消息并等待发送结果，发件箱重试时使用
+func (w *WechatNotify) sendSync(msg *Message) error {
+	done := make(chan error, 1)
+	w.enqueue(newWechatSend(msg, done), w.postsFor(msg))
+	return <-done
+}
+
+// postsFor 按配置的消息类型生成企业微信消息，并 @ 提醒消息中的企业微信用户与手机号
+// 模板卡片必须带跳转链接，消息没有链接时改为发送 markdown
+func (w *WechatNotify) postsFor(msg *Message) []*wechatPost {
+	switch {
+	case w.msgType == WechatMsgTypeTemplateCard && msg.URL != "":
+		// 模板卡片不支持 @ 提醒，另发一条文本消息
+		posts := []*wechatPost{{msgType: WechatMsgTypeTemplateCard, card: wechatTemplateCard(msg)}}
+		return append(posts, mentionPosts(msg, msg.Mentions, msg.MentionMobiles)...)
+	case w.msgType == WechatMsgTypeMarkdown || w.msgType == WechatMsgTypeTemplateCard:
+		// markdown 以 <@userid> 提醒用户，不支持按手机号提醒
+		posts := []*wechatPost{{msgType: WechatMsgTypeMarkdown, content: wechatMarkdown(msg)}}
+		return append(posts, mentionPosts(msg, nil, msg.MentionMobiles)...)
+	default:
+		return []*wechatPost{{msgType: WechatMsgTypeText, content: msg.Text(), mentions: msg.Mentions, mobiles: msg.MentionMobiles}}
 	}
+}
 
-	// 构造企业微信文本消息
-	payload := map[string]interface{}{
-		"msgtype": WechatMsgTypeText,
-		"text": map[string]string{
-			"content": message,
-		},
+// mentionPosts 返回 @ 提醒的文本消息，没有需要提醒的用户时为空
+func mentionPosts(msg *Message, mentionedList, mobileList []string) []*wechatPost {
+	if len(mentionedList) == 0 && len(mobileList) == 0 {
+		return nil
 	}
+	return []*wechatPost{{msgType: WechatMsgTypeText, content: "请及时处理: " + msg.subject(), mentions: mentionedList, mobiles: mobileList}}
+}
+
+// SendToWechat 发送消息到企业微信并等待发送结果
+func (w *WechatNotify) SendToWechat(message string) error {
+	return w.SendToWechatMention(message, nil)
+}
+
+// SendToWechatMention 发送消息到企业微信并等待发送结果，@ 提醒 mentionedList 中的企业微信用户
+func (w *WechatNotify) SendToWechatMention(message string, mentionedList []string) error {
+	done := make(chan error, 1)
+	msg := &Message{Content: message, Mentions: mentionedList}
+	w.enqueue(newWechatSend(msg, done), []*wechatPost{{msgType: WechatMsgTypeText, content: message, mentions: mentionedList}})
+	return <-done
+}
 
+// post 发送企业微信机器人消息
+func (w *WechatNotify) post(payload map[string]interface{}) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("JSON 序列化失败: %w", err)
@@ -94,11 +160,20 @@ func (w *WechatNotify) SendToWechat(message string) error {
 		return fmt.Errorf("webhook 返回错误状态码: %d", resp.StatusCode)
 	}
 
+	// HTTP 200 时仍需检查 errcode，如 45009 表示发送频率超限
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		zap.S().Errorf("解析 webhook 响应失败: %v, 响应内容: %s", err, string(body))
+		return fmt.Errorf("解析 webhook 响应失败: %w", err)
+	}
+	if result.ErrCode != 0 {
+		zap.S().Errorf("Webhook 返回错误: errcode=%d, errmsg=%s", result.ErrCode, result.ErrMsg)
+		return fmt.Errorf("webhook 返回错误: errcode=%d, errmsg=%s", result.ErrCode, result.ErrMsg)
+	}
+
 	zap.S().Infof("Webhook 消息发送成功: %s", string(body))
 	return nil
 }
-
-// ProvideWechatNotify 提供通知器依赖注入
-func ProvideWechatNotify() fx.Option {
-	return fx.Provide(NewWechatNotify)
-}
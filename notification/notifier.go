@@ -0,0 +1,316 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/storage"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+const (
+	// ChannelWecom 企业微信群机器人
+	ChannelWecom = "wecom"
+	// ChannelDingTalk 钉钉群机器人
+	ChannelDingTalk = "dingtalk"
+	// ChannelFeishu 飞书群机器人
+	ChannelFeishu = "feishu"
+	// ChannelSlack Slack incoming webhook
+	ChannelSlack = "slack"
+	// ChannelTelegram Telegram Bot API
+	ChannelTelegram = "telegram"
+	// ChannelWebhook 通用 JSON webhook
+	ChannelWebhook = "webhook"
+)
+
+// 通知事件类型，邮件渠道按事件类型选择收件人
+const (
+	// EventAlert 告警任务通知
+	EventAlert = "alert"
+	// EventEscalation 告警升级通知
+	EventEscalation = "escalation"
+	// EventAck 告警确认通知
+	EventAck = "ack"
+	// EventResolved 告警恢复通知
+	EventResolved = "resolved"
+	// EventLimit 拨号限流通知
+	EventLimit = "limit"
+	// EventNetwork EC600N 网络状态报告与网络异常通知
+	EventNetwork = "network"
+	// EventSMS 收到短信通知
+	EventSMS = "sms"
+)
+
+// Field 通知消息字段
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Message 通知消息
+// 文本内容由 Title、Fields、Note 逐行生成，设置 Content 时使用 Content；
+// 邮件、企业微信 markdown 与模板卡片以 Title 与 Fields 生成富文本
+type Message struct {
+	Event          string   `json:"event,omitempty"`          // 事件类型
+	Title          string   `json:"title,omitempty"`          // 标题，作为邮件主题
+	Fields         []Field  `json:"fields,omitempty"`         // 消息字段，按 "名称: 值" 逐行输出
+	Note           string   `json:"note,omitempty"`           // 附加说明，输出在字段之后
+	Content        string   `json:"content,omitempty"`        // 文本内容，为空时由 Title、Fields、Note 生成
+	Severity       string   `json:"severity,omitempty"`       // 告警级别，企业微信富文本消息以颜色与标签展示
+	URL            string   `json:"url,omitempty"`            // 详情链接（任务状态）
+	Mentions       []string `json:"mentions,omitempty"`       // 需要 @ 提醒的企业微信用户 ID，仅企业微信渠道使用
+	MentionMobiles []string `json:"mentionMobiles,omitempty"` // 需要 @ 提醒的手机号，仅企业微信渠道使用
+}
+
+// Text 返回消息的文本内容
+func (m *Message) Text() string {
+	if m.Content != "" {
+		return m.Content
+	}
+	lines := make([]string, 0, 3)
+	if m.Title != "" {
+		lines = append(lines, m.Title)
+	}
+	if len(m.Fields) > 0 {
+		lines = append(lines, FormatFields(m.Fields))
+	}
+	if m.Note != "" {
+		lines = append(lines, m.Note)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// subject 返回消息标题，未设置标题时为文本内容的第一行
+func (m *Message) subject() string {
+	if m.Title != "" {
+		return m.Title
+	}
+	subject, _, _ := strings.Cut(m.Text(), "\n")
+	return subject
+}
+
+// FormatFields 按 "名称: 值" 逐行输出字段
+func FormatFields(fields []Field) string {
+	lines := make([]string, 0, len(fields))
+	for _, f := range fields {
+		lines = append(lines, f.Name+": "+f.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	// Name 返回渠道名称
+	Name() string
+	// Send 发送消息
+	Send(msg *Message) error
+}
+
+// syncNotifier 在后台异步发送的渠道，sendSync 发送并等待结果，供发件箱重试使用
+type syncNotifier interface {
+	sendSync(msg *Message) error
+}
+
+// Registry 按配置创建的通知渠道集合，消息发送到全部渠道
+// 启用发件箱时，发送失败的渠道由发件箱在后台重试；企业微信在后台发送，失败时同样加入发件箱
+type Registry struct {
+	channels []Notifier
+	outbox   *Outbox
+}
+
+// NewRegistry 根据配置创建通知渠道
+// wechat.webhook_url 配置的企业微信群始终作为第一个渠道；未配置任何渠道时只记录日志
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	r := &Registry{}
+	if !validWechatMsgType(cfg.Wechat.MsgType) {
+		return nil, fmt.Errorf("wechat.msg_type 不支持: %s", cfg.Wechat.MsgType)
+	}
+	if cfg.Wechat.WebhookURL != "" {
+		r.channels = append(r.channels, NewWechatNotify(cfg))
+	}
+	names := make(map[string]bool)
+	for _, channel := range r.channels {
+		names[channel.Name()] = true
+	}
+	for i, c := range cfg.Notifiers {
+		channel, err := newChannel(cfg, c)
+		if err != nil {
+			return nil, fmt.Errorf("notifiers[%d]: %w", i, err)
+		}
+		// 发件箱按名称重试渠道，名称不能重复
+		if names[channel.Name()] {
+			return nil, fmt.Errorf("notifiers[%d]: 渠道名称重复: %s", i, channel.Name())
+		}
+		names[channel.Name()] = true
+		r.channels = append(r.channels, channel)
+	}
+	return r, nil
+}
+
+// NewRegistryFromConfig 根据配置创建通知渠道，并启用保存在数据目录中的发件箱
+func NewRegistryFromConfig(cfg *config.Config) (*Registry, error) {
+	r, err := NewRegistry(cfg)
+	if err != nil {
+		return nil, err
+	}
+	file, err := storage.NewJSONFile(storage.DataDir(cfg), OutboxFileName)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := NewOutbox(cfg, file, r.sendTo)
+	if err != nil {
+		return nil, err
+	}
+	r.useOutbox(outbox)
+	return r, nil
+}
+
+// useOutbox 启用发件箱，企业微信后台发送失败的消息也加入发件箱
+func (r *Registry) useOutbox(outbox *Outbox) {
+	r.outbox = outbox
+	for _, channel := range r.channels {
+		if w, ok := channel.(*WechatNotify); ok {
+			name := w.Name()
+			w.onFailure = func(msg *Message, err error) {
+				zap.S().Errorf("通知渠道 %s 发送失败: %v", name, err)
+				if addErr := outbox.Add(msg, []string{name}, err); addErr != nil {
+					zap.S().Errorf("通知加入发件箱失败: %v", addErr)
+				}
+			}
+		}
+	}
+}
+
+// newChannel 根据渠道配置创建通知渠道
+func newChannel(cfg *config.Config, c config.Notifier) (Notifier, error) {
+	name := c.Name
+	if name == "" {
+		name = c.Type
+	}
+	if c.Type != ChannelTelegram && c.Type != ChannelEmail && c.WebhookURL == "" {
+		return nil, fmt.Errorf("渠道 %s 未配置 webhook_url", name)
+	}
+
+	switch c.Type {
+	case ChannelWecom:
+		if !validWechatMsgType(c.MsgType) {
+			return nil, fmt.Errorf("渠道 %s 不支持的消息类型: %s", name, c.MsgType)
+		}
+		return newWechatNotify(cfg, name, c.WebhookURL, c.MsgType), nil
+	case ChannelDingTalk:
+		return &DingTalkNotify{name: name, webhookURL: c.WebhookURL, secret: c.Secret, client: newHTTPClient()}, nil
+	case ChannelFeishu:
+		return &FeishuNotify{name: name, webhookURL: c.WebhookURL, secret: c.Secret, client: newHTTPClient()}, nil
+	case ChannelSlack:
+		return &SlackNotify{name: name, webhookURL: c.WebhookURL, client: newHTTPClient()}, nil
+	case ChannelTelegram:
+		if c.BotToken == "" || c.ChatID == "" {
+			return nil, fmt.Errorf("渠道 %s 未配置 bot_token 或 chat_id", name)
+		}
+		apiURL := c.WebhookURL
+		if apiURL == "" {
+			apiURL = DefaultTelegramAPI
+		}
+		return &TelegramNotify{name: name, apiURL: apiURL, token: c.BotToken, chatID: c.ChatID, client: newHTTPClient()}, nil
+	case ChannelWebhook:
+		return &WebhookNotify{name: name, webhookURL: c.WebhookURL, headers: c.Headers, client: newHTTPClient()}, nil
+	case ChannelEmail:
+		return newEmailNotify(name, c)
+	default:
+		return nil, fmt.Errorf("不支持的渠道类型: %s", c.Type)
+	}
+}
+
+// Name 返回渠道名称
+func (r *Registry) Name() string {
+	return "registry"
+}
+
+// Channels 返回全部通知渠道
+func (r *Registry) Channels() []Notifier {
+	return r.channels
+}
+
+// Outbox 返回发件箱，未启用时为 nil
+func (r *Registry) Outbox() *Outbox {
+	return r.outbox
+}
+
+// Send 发送消息到全部渠道，单个渠道失败不影响其他渠道，返回所有失败渠道的错误
+// 启用发件箱时，失败的渠道加入发件箱稍后重试
+func (r *Registry) Send(msg *Message) error {
+	// 始终记录日志
+	zap.S().Infof("[通知] %s", msg.Text())
+
+	var errs []error
+	var failed []string
+	for _, channel := range r.channels {
+		if err := channel.Send(msg); err != nil {
+			zap.S().Errorf("通知渠道 %s 发送失败: %v", channel.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+			failed = append(failed, channel.Name())
+		}
+	}
+	err := errors.Join(errs...)
+	if len(failed) > 0 && r.outbox != nil {
+		if addErr := r.outbox.Add(msg, failed, err); addErr != nil {
+			zap.S().Errorf("通知加入发件箱失败: %v", addErr)
+		}
+	}
+	return err
+}
+
+// sendTo 发送消息到指定名称的渠道并等待发送结果
+func (r *Registry) sendTo(name string, msg *Message) error {
+	for _, channel := range r.channels {
+		if channel.Name() != name {
+			continue
+		}
+		if s, ok := channel.(syncNotifier); ok {
+			return s.sendSync(msg)
+		}
+		return channel.Send(msg)
+	}
+	return fmt.Errorf("通知渠道不存在: %s", name)
+}
+
+// ProvideNotifier 提供通知渠道依赖注入，并在服务运行期间启动发件箱重试
+func ProvideNotifier() fx.Option {
+	return fx.Options(
+		fx.Provide(
+			NewRegistryFromConfig,
+			func(r *Registry) Notifier { return r },
+		),
+		fx.Invoke(registerNotifierLifecycle),
+	)
+}
+
+// registerNotifierLifecycle 注册通知渠道生命周期，服务启动时开始后台发送与发件箱重试，停止时结束
+// 先停止发件箱重试，再停止企业微信后台发送，未发送的消息加入发件箱
+func registerNotifierLifecycle(lifecycle fx.Lifecycle, r *Registry) {
+	var stops []func()
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			for _, channel := range r.channels {
+				if w, ok := channel.(*WechatNotify); ok {
+					stops = append(stops, w.Start())
+				}
+			}
+			if r.outbox != nil {
+				stops = append(stops, r.outbox.Start())
+			}
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			for i := len(stops) - 1; i >= 0; i-- {
+				stops[i]()
+			}
+			return nil
+		},
+	})
+}
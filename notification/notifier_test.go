@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	var dingtalk, feishu, telegram, generic map[string]any
+	var dingtalkQuery, header string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dingtalk", func(w http.ResponseWriter, r *http.Request) {
+		dingtalkQuery = r.URL.RawQuery
+		json.NewDecoder(r.Body).Decode(&dingtalk)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	})
+	mux.HandleFunc("/feishu", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&feishu)
+		w.Write([]byte(`{"code":19021,"msg":"sign match fail"}`))
+	})
+	mux.HandleFunc("/slack", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/botTOKEN/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&telegram)
+		w.Write([]byte(`{"ok":true}`))
+	})
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&generic)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cfg := &config.Config{Notifiers: []config.Notifier{
+		{Type: ChannelDingTalk, WebhookURL: ts.URL + "/dingtalk?access_token=x", Secret: "SEC1"},
+		{Name: "lark", Type: ChannelFeishu, WebhookURL: ts.URL + "/feishu", Secret: "secret"},
+		{Type: ChannelSlack, WebhookURL: ts.URL + "/slack"},
+		{Type: ChannelTelegram, WebhookURL: ts.URL, BotToken: "TOKEN", ChatID: "-100"},
+		{Type: ChannelWebhook, WebhookURL: ts.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer t"}},
+	}}
+	registry, err := NewRegistry(cfg)
+	require.NoError(t, err)
+	require.Len(t, registry.Channels(), 5)
+
+	// 单个渠道失败时其他渠道仍然发送
+	err = registry.Send(&Message{Content: "磁盘告警", Mentions: []string{"zhangsan"}})
+	assert.ErrorContains(t, err, "lark")
+	assert.NotContains(t, err.Error(), "dingtalk")
+
+	assert.Equal(t, "磁盘告警", dingtalk["text"].(map[string]any)["content"])
+	assert.Contains(t, dingtalkQuery, "access_token=x&sign=")
+	assert.Equal(t, "磁盘告警", feishu["content"].(map[string]any)["text"])
+	timestamp := feishu["timestamp"].(string)
+	assert.Equal(t, feishuSign("secret", timestamp), feishu["sign"])
+	sec, _ := strconv.ParseInt(timestamp, 10, 64)
+	assert.WithinDuration(t, time.Now(), time.Unix(sec, 0), time.Minute)
+	assert.Equal(t, "-100", telegram["chat_id"])
+	assert.Equal(t, "Bearer t", header)
+	assert.Equal(t, []any{"zhangsan"}, generic["mentions"])
+
+	// 钉钉加签：HMAC-SHA256(secret, "timestamp\nsecret") 的 base64
+	assert.Equal(t, "ukR4+WoOOBTGaOauYqugxVrMSFyr92q4hPxJxuOkiio=", dingTalkSign("SEC1", "1700000000000"))
+
+	_, err = NewRegistry(&config.Config{Notifiers: []config.Notifier{{Type: "sms", WebhookURL: "x"}}})
+	assert.Error(t, err)
+	_, err = NewRegistry(&config.Config{Notifiers: []config.Notifier{{Type: ChannelTelegram}}})
+	assert.Error(t, err)
+}
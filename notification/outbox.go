@@ -0,0 +1,333 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"sync"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/storage"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// OutboxFileName 发件箱文件名
+	OutboxFileName = "notify_outbox.json"
+	// OutboxMaxEntries 发件箱最多保留的通知条数，超过时丢弃最早的通知
+	OutboxMaxEntries = 1000
+
+	// DefaultOutboxMaxAttempts 默认每个渠道最多发送次数（含首次发送）
+	DefaultOutboxMaxAttempts = 5
+	// DefaultOutboxInitialBackoff 默认首次重试间隔
+	DefaultOutboxInitialBackoff = 10 * time.Second
+	// DefaultOutboxMaxBackoff 默认最大重试间隔
+	DefaultOutboxMaxBackoff = 10 * time.Minute
+)
+
+// DefaultFallbackEvents 默认需要短信兜底的事件类型，网络状态报告与短信转发不发送短信
+var DefaultFallbackEvents = []string{EventAlert, EventEscalation, EventAck, EventResolved, EventLimit}
+
+// SMSFallback 发送短信，用于通知多次发送失败后的兜底
+type SMSFallback func(phoneNumber, text string) error
+
+// outboxEntry 发件箱中待重试的通知
+type outboxEntry struct {
+	ID          string    `json:"id"`
+	Message     *Message  `json:"message"`
+	Channels    []string  `json:"channels"`            // 尚未发送成功的渠道
+	Attempts    int       `json:"attempts"`            // 已发送次数
+	NextAttempt time.Time `json:"nextAttempt"`         // 下次重试时间
+	LastError   string    `json:"lastError,omitempty"` // 最近一次发送失败的原因
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Outbox 通知发件箱
+// 发送失败的通知保存在本地文件中，按指数退避加随机抖动重试，服务重启后继续重试；
+// 达到最多发送次数后放弃重试，并通过短信通知兜底号码
+type Outbox struct {
+	mu      sync.Mutex
+	file    *storage.JSONFile
+	entries []*outboxEntry
+	send    func(channel string, msg *Message) error
+	wakeup  chan struct{}
+
+	maxAttempts     int
+	initialBackoff  time.Duration
+	maxBackoff      time.Duration
+	fallback        SMSFallback
+	fallbackNumbers []string
+	fallbackEvents  map[string]bool
+}
+
+// NewOutbox 创建发件箱，send 按渠道名称发送消息；file 为 nil 时仅保存在内存中
+func NewOutbox(cfg *config.Config, file *storage.JSONFile, send func(channel string, msg *Message) error) (*Outbox, error) {
+	o := &Outbox{
+		file:            file,
+		send:            send,
+		wakeup:          make(chan struct{}, 1),
+		maxAttempts:     cfg.Outbox.MaxAttempts,
+		initialBackoff:  time.Duration(cfg.Outbox.InitialBackoff) * time.Second,
+		maxBackoff:      time.Duration(cfg.Outbox.MaxBackoff) * time.Second,
+		fallbackNumbers: cfg.Outbox.FallbackNumbers,
+		fallbackEvents:  make(map[string]bool),
+	}
+	if o.maxAttempts <= 0 {
+		o.maxAttempts = DefaultOutboxMaxAttempts
+	}
+	if o.initialBackoff <= 0 {
+		o.initialBackoff = DefaultOutboxInitialBackoff
+	}
+	if o.maxBackoff <= 0 {
+		o.maxBackoff = DefaultOutboxMaxBackoff
+	}
+	events := cfg.Outbox.FallbackEvents
+	if len(events) == 0 {
+		events = DefaultFallbackEvents
+	}
+	for _, event := range events {
+		o.fallbackEvents[event] = true
+	}
+
+	if file == nil {
+		return o, nil
+	}
+	if _, err := file.Load(&o.entries); err != nil {
+		return nil, fmt.Errorf("加载通知发件箱失败: %w", err)
+	}
+	if len(o.entries) > 0 {
+		zap.S().Infof("恢复未发送成功的通知: %d 条", len(o.entries))
+	}
+	return o, nil
+}
+
+// SetSMSFallback 设置短信兜底发送方式，未设置时多次发送失败的通知只记录日志
+func (o *Outbox) SetSMSFallback(fallback SMSFallback) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fallback = fallback
+}
+
+// Add 将首次发送失败的通知加入发件箱，channels 为发送失败的渠道
+func (o *Outbox) Add(msg *Message, channels []string, sendErr error) error {
+	id, err := newOutboxID()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := &outboxEntry{
+		ID:          id,
+		Message:     msg,
+		Channels:    channels,
+		Attempts:    1,
+		NextAttempt: now.Add(o.backoff(1)),
+		CreatedAt:   now,
+	}
+	if sendErr != nil {
+		entry.LastError = sendErr.Error()
+	}
+
+	o.mu.Lock()
+	if o.maxAttempts <= 1 {
+		o.mu.Unlock()
+		o.giveUp(entry)
+		return nil
+	}
+	o.entries = append(o.entries, entry)
+	if len(o.entries) > OutboxMaxEntries {
+		dropped := len(o.entries) - OutboxMaxEntries
+		zap.S().Warnf("通知发件箱已满，丢弃最早的 %d 条通知", dropped)
+		o.entries = o.entries[dropped:]
+	}
+	err = o.save()
+	o.mu.Unlock()
+
+	zap.S().Warnf("通知已加入发件箱: id=%s, 渠道=%v, %s 后重试", entry.ID, channels, entry.NextAttempt.Sub(now).Round(time.Second))
+	o.signal()
+	return err
+}
+
+// Pending 返回发件箱中待重试的通知数
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Start 启动后台重试，返回停止函数
+func (o *Outbox) Start() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			wait := o.retry(time.Now())
+			if err := o.wait(ctx, wait); err != nil {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// wait 等待 wait 时长、有新通知加入或 ctx 取消，wait 为 0 时一直等待
+func (o *Outbox) wait(ctx context.Context, wait time.Duration) error {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-o.wakeup:
+	case <-timeout:
+	}
+	return nil
+}
+
+// retry 重试到期的通知，返回距下一条通知到期的时间，发件箱为空时返回 0
+func (o *Outbox) retry(now time.Time) time.Duration {
+	o.mu.Lock()
+	var due []*outboxEntry
+	for _, e := range o.entries {
+		if !e.NextAttempt.After(now) {
+			due = append(due, e)
+		}
+	}
+	o.mu.Unlock()
+
+	type result struct {
+		failed []string
+		err    error
+	}
+	results := make(map[*outboxEntry]result, len(due))
+	for _, e := range due {
+		var r result
+		for _, channel := range e.Channels {
+			if err := o.send(channel, e.Message); err != nil {
+				zap.S().Warnf("通知重试失败: id=%s, 渠道=%s, 第 %d 次, %v", e.ID, channel, e.Attempts+1, err)
+				r.failed = append(r.failed, channel)
+				r.err = err
+			}
+		}
+		results[e] = r
+	}
+
+	o.mu.Lock()
+	var exhausted []*outboxEntry
+	kept := o.entries[:0]
+	for _, e := range o.entries {
+		r, retried := results[e]
+		if !retried {
+			kept = append(kept, e)
+			continue
+		}
+		if len(r.failed) == 0 {
+			zap.S().Infof("通知重试成功: id=%s, 第 %d 次", e.ID, e.Attempts+1)
+			continue
+		}
+		e.Attempts++
+		e.Channels = r.failed
+		e.LastError = r.err.Error()
+		if e.Attempts >= o.maxAttempts {
+			exhausted = append(exhausted, e)
+			continue
+		}
+		e.NextAttempt = now.Add(o.backoff(e.Attempts))
+		kept = append(kept, e)
+	}
+	o.entries = kept
+	if len(results) > 0 {
+		if err := o.save(); err != nil {
+			zap.S().Errorf("保存通知发件箱失败: %v", err)
+		}
+	}
+
+	var wait time.Duration
+	for _, e := range o.entries {
+		if d := e.NextAttempt.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	if len(o.entries) > 0 && wait <= 0 {
+		wait = time.Millisecond
+	}
+	o.mu.Unlock()
+
+	for _, e := range exhausted {
+		o.giveUp(e)
+	}
+	return wait
+}
+
+// giveUp 放弃重试，需要兜底的事件通过短信发送到兜底号码
+func (o *Outbox) giveUp(e *outboxEntry) {
+	zap.S().Errorf("通知发送失败，放弃重试: id=%s, 渠道=%v, 共 %d 次, %s", e.ID, e.Channels, e.Attempts, e.LastError)
+
+	o.mu.Lock()
+	fallback := o.fallback
+	o.mu.Unlock()
+	if fallback == nil || len(o.fallbackNumbers) == 0 || !o.fallbackEvents[e.Message.Event] {
+		return
+	}
+
+	text := e.Message.Text()
+	for _, number := range o.fallbackNumbers {
+		if err := fallback(number, text); err != nil {
+			zap.S().Errorf("短信兜底发送失败 [%s]: %v", number, err)
+			continue
+		}
+		zap.S().Infof("通知已改为短信发送: id=%s, number=%s", e.ID, number)
+	}
+}
+
+// backoff 返回第 attempts 次发送失败后的重试间隔
+// 间隔从 initialBackoff 开始每次翻倍，不超过 maxBackoff，并在 [间隔/2, 间隔] 内随机抖动，避免集中重试
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.initialBackoff
+	for i := 1; i < attempts && d < o.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > o.maxBackoff {
+		d = o.maxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(mathrand.Int63n(int64(d-half)+1))
+}
+
+// save 持久化发件箱，调用方需持有锁
+func (o *Outbox) save() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Save(o.entries)
+}
+
+// signal 唤醒重试协程
+func (o *Outbox) signal() {
+	select {
+	case o.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// newOutboxID 生成随机通知 ID
+func newOutboxID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成通知 ID 失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"alert-mobile-notify/config"
+	"alert-mobile-notify/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifier 测试用通知渠道，fail 为 true 时发送失败
+type fakeNotifier struct {
+	name string
+	fail bool
+	sent int
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Send(msg *Message) error {
+	if f.fail {
+		return errors.New("timeout")
+	}
+	f.sent++
+	return nil
+}
+
+func TestOutbox(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Outbox.MaxAttempts = 3
+	cfg.Outbox.FallbackNumbers = []string{"13800138000"}
+	file, err := storage.NewJSONFile(t.TempDir(), OutboxFileName)
+	require.NoError(t, err)
+
+	ok := &fakeNotifier{name: "ok"}
+	flaky := &fakeNotifier{name: "flaky", fail: true}
+	registry := &Registry{channels: []Notifier{ok, flaky}}
+	registry.outbox, err = NewOutbox(cfg, file, registry.sendTo)
+	require.NoError(t, err)
+	var sms []string
+	registry.outbox.SetSMSFallback(func(number, text string) error {
+		sms = append(sms, number+": "+text)
+		return nil
+	})
+
+	// 只重试失败的渠道
+	assert.Error(t, registry.Send(&Message{Event: EventAlert, Title: "📞 告警通知", Fields: []Field{{Name: "名称", Value: "disk"}}}))
+	assert.Equal(t, 1, registry.outbox.Pending())
+	// 未到重试时间时不发送，返回等待时间
+	now := time.Now()
+	wait := registry.outbox.retry(now)
+	assert.True(t, wait > 0 && wait <= DefaultOutboxInitialBackoff, wait)
+	assert.Equal(t, 1, registry.outbox.entries[0].Attempts)
+	registry.outbox.retry(now.Add(time.Hour))
+	assert.Equal(t, 1, ok.sent)
+	assert.Equal(t, 1, registry.outbox.Pending())
+
+	// 重启后从文件恢复
+	reloaded, err := NewOutbox(cfg, file, registry.sendTo)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reloaded.Pending())
+	assert.Equal(t, 2, reloaded.entries[0].Attempts)
+	assert.Equal(t, []string{"flaky"}, reloaded.entries[0].Channels)
+
+	// 达到最多发送次数后改为短信通知
+	registry.outbox.retry(now.Add(2 * time.Hour))
+	assert.Equal(t, 0, registry.outbox.Pending())
+	assert.Equal(t, []string{"13800138000: 📞 告警通知\n名称: disk"}, sms)
+
+	// 重试成功后移出发件箱；网络状态报告不发送短信
+	assert.Error(t, registry.Send(&Message{Event: EventNetwork, Content: "EC600N 网络状态报告"}))
+	flaky.fail = false
+	assert.Equal(t, time.Duration(0), registry.outbox.retry(now.Add(3*time.Hour)))
+	assert.Equal(t, 0, registry.outbox.Pending())
+	assert.Equal(t, 1, flaky.sent)
+	assert.Len(t, sms, 1)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Outbox.InitialBackoff = 10
+	cfg.Outbox.MaxBackoff = 60
+	o, err := NewOutbox(cfg, nil, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		d := o.backoff(1)
+		assert.True(t, d >= 5*time.Second && d <= 10*time.Second, d)
+		d = o.backoff(3)
+		assert.True(t, d >= 20*time.Second && d <= 40*time.Second, d)
+		d = o.backoff(10)
+		assert.True(t, d >= 30*time.Second && d <= 60*time.Second, d)
+	}
+}
+
+func TestWechatErrCode(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+	}))
+	defer ts.Close()
+
+	wechat := newWechatNotify(&config.Config{}, ChannelWecom, ts.URL, WechatMsgTypeText)
+	assert.ErrorContains(t, wechat.SendToWechat("test"), "errcode=45009")
+}
@@ -0,0 +1,122 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// wechatCardMaxItems 模板卡片二级标题+文本列表最多条数
+	wechatCardMaxItems = 6
+)
+
+// severityLevel 告警级别的展示方式
+type severityLevel struct {
+	label     string // 级别标签
+	color     string // markdown 字体颜色：info（绿色）、comment（灰色）、warning（橙红色）
+	descColor int    // 模板卡片来源文字颜色：0 灰色、1 黑色、2 红色、3 绿色
+	rank      int    // 严重程度，数值越大越严重
+}
+
+// severityOf 返回告警级别的展示方式，未设置级别时返回 nil
+// 常见的 Prometheus、Grafana、Zabbix 级别名称映射为严重、警告、提示三级，其他级别以原值展示
+func severityOf(severity string) *severityLevel {
+	switch s := strings.ToLower(strings.TrimSpace(severity)); s {
+	case "":
+		return nil
+	case "critical", "disaster", "high", "fatal", "emergency", "error", "p0", "p1":
+		return &severityLevel{label: "🔴 严重", color: "warning", descColor: 2, rank: 3}
+	case "warning", "warn", "average", "medium", "p2":
+		return &severityLevel{label: "🟠 警告", color: "warning", descColor: 1, rank: 2}
+	case "info", "information", "low", "not classified", "p3", "p4":
+		return &severityLevel{label: "🔵 提示", color: "info", descColor: 3, rank: 1}
+	default:
+		return &severityLevel{label: severity, color: "comment", descColor: 0, rank: 1}
+	}
+}
+
+// SeverityRank 返回告警级别的严重程度：严重 3、警告 2、提示及其他级别 1、未设置 0
+func SeverityRank(severity string) int {
+	if level := severityOf(severity); level != nil {
+		return level.rank
+	}
+	return 0
+}
+
+// wechatMarkdown 生成企业微信 markdown 消息内容
+// 字段以引用块展示，级别以颜色标注，末尾附任务状态链接并以 <@userid> 提醒用户
+func wechatMarkdown(msg *Message) string {
+	var b strings.Builder
+	if msg.Title != "" {
+		fmt.Fprintf(&b, "### %s\n", msg.Title)
+	}
+	if level := severityOf(msg.Severity); level != nil {
+		fmt.Fprintf(&b, "> 级别: <font color=\"%s\">%s</font>\n", level.color, level.label)
+	}
+	if len(msg.Fields) > 0 {
+		for _, f := range msg.Fields {
+			value := strings.ReplaceAll(f.Value, "\n", "\n> ")
+			fmt.Fprintf(&b, "> %s: <font color=\"comment\">%s</font>\n", f.Name, value)
+		}
+	} else if msg.Content != "" {
+		b.WriteString(msg.Content + "\n")
+	}
+	if msg.Note != "" {
+		fmt.Fprintf(&b, "\n%s\n", msg.Note)
+	}
+	if msg.URL != "" {
+		fmt.Fprintf(&b, "\n[查看任务状态](%s)\n", msg.URL)
+	}
+	if len(msg.Mentions) > 0 {
+		mentions := make([]string, 0, len(msg.Mentions))
+		for _, id := range msg.Mentions {
+			mentions = append(mentions, "<@"+id+">")
+		}
+		fmt.Fprintf(&b, "\n%s\n", strings.Join(mentions, " "))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// wechatTemplateCard 生成企业微信文本通知模板卡片，点击卡片跳转到任务状态
+func wechatTemplateCard(msg *Message) map[string]interface{} {
+	source := map[string]interface{}{"desc": "告警通知"}
+	card := map[string]interface{}{
+		"card_type":  "text_notice",
+		"source":     source,
+		"main_title": map[string]interface{}{"title": msg.subject(), "desc": msg.Note},
+		"jump_list": []map[string]interface{}{
+			{"type": 1, "title": "查看任务状态", "url": msg.URL},
+		},
+		"card_action": map[string]interface{}{"type": 1, "url": msg.URL},
+	}
+	if level := severityOf(msg.Severity); level != nil {
+		source["desc_color"] = level.descColor
+		card["emphasis_content"] = map[string]interface{}{"title": level.label, "desc": "告警级别"}
+	}
+
+	items := make([]map[string]interface{}, 0, wechatCardMaxItems)
+	for _, f := range msg.Fields {
+		if len(items) == wechatCardMaxItems {
+			break
+		}
+		items = append(items, map[string]interface{}{
+			"keyname": f.Name,
+			"value":   strings.ReplaceAll(f.Value, "\n", " "),
+		})
+	}
+	if len(items) > 0 {
+		card["horizontal_content_list"] = items
+	} else if msg.Content != "" {
+		card["sub_title_text"] = msg.Content
+	}
+	return card
+}
+
+// validWechatMsgType 是否为支持的企业微信消息类型，为空时使用文本消息
+func validWechatMsgType(msgType string) bool {
+	switch msgType {
+	case "", WechatMsgTypeText, WechatMsgTypeMarkdown, WechatMsgTypeTemplateCard:
+		return true
+	}
+	return false
+}
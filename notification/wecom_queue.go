@@ -0,0 +1,367 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"alert-mobile-notify/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWechatRateLimit 企业微信群机器人默认每分钟最多发送消息数
+	DefaultWechatRateLimit = 20
+	// WechatMaxTextBytes 企业微信文本消息内容最大字节数
+	WechatMaxTextBytes = 2048
+	// WechatMaxMarkdownBytes 企业微信 markdown 消息内容最大字节数
+	WechatMaxMarkdownBytes = 4096
+	// wechatMergeSeparator 合并消息之间的分隔
+	wechatMergeSeparator = "\n\n"
+)
+
+// errWechatStopped 服务停止时队列中尚未发送的消息返回该错误
+var errWechatStopped = errors.New("企业微信发送队列已停止")
+
+// wechatRateLimit 返回配置的企业微信每分钟最多发送消息数
+func wechatRateLimit(cfg *config.Config) int {
+	if cfg.Wechat.RateLimit > 0 {
+		return cfg.Wechat.RateLimit
+	}
+	return DefaultWechatRateLimit
+}
+
+// wechatPost 待发送的企业微信消息
+type wechatPost struct {
+	msgType  string
+	content  string                 // 文本或 markdown 内容
+	card     map[string]interface{} // 模板卡片
+	mentions []string               // @ 提醒的企业微信用户 ID，仅文本消息
+	mobiles  []string               // @ 提醒的手机号，仅文本消息
+	send     *wechatSend            // 所属的通知消息
+}
+
+// wechatSend 一条通知消息的发送，消息对应的企业微信消息全部发送后结束
+type wechatSend struct {
+	msg  *Message
+	done chan error // 不为 nil 时写入发送结果（同步发送），为 nil 时失败交给 onFailure
+
+	mu      sync.Mutex
+	pending int
+	errs    []error
+}
+
+// newWechatSend 创建通知消息的发送
+func newWechatSend(msg *Message, done chan error) *wechatSend {
+	return &wechatSend{msg: msg, done: done}
+}
+
+// complete 记录一条企业微信消息的发送结果，全部发送完成时返回 true 与合并后的错误
+func (s *wechatSend) complete(err error) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errs = append(s.errs, err)
+	}
+	s.pending--
+	return s.pending == 0, errors.Join(s.errs...)
+}
+
+// payload 返回企业微信机器人消息请求体
+func (p *wechatPost) payload() map[string]interface{} {
+	switch p.msgType {
+	case WechatMsgTypeTemplateCard:
+		return map[string]interface{}{"msgtype": WechatMsgTypeTemplateCard, "template_card": p.card}
+	case WechatMsgTypeMarkdown:
+		return map[string]interface{}{"msgtype": WechatMsgTypeMarkdown, "markdown": map[string]interface{}{"content": p.content}}
+	}
+
+	text := map[string]interface{}{"content": p.content}
+	if len(p.mentions) > 0 {
+		text["mentioned_list"] = p.mentions
+	}
+	if len(p.mobiles) > 0 {
+		text["mentioned_mobile_list"] = p.mobiles
+	}
+	return map[string]interface{}{"msgtype": WechatMsgTypeText, "text": text}
+}
+
+// contentLimit 返回消息内容最大字节数，模板卡片为 0
+func (p *wechatPost) contentLimit() int {
+	switch p.msgType {
+	case WechatMsgTypeText:
+		return WechatMaxTextBytes
+	case WechatMsgTypeMarkdown:
+		return WechatMaxMarkdownBytes
+	}
+	return 0
+}
+
+// enqueue 将通知消息对应的企业微信消息加入发送队列
+// 超长的消息按内容上限拆分为多条依次发送，@ 提醒只附加在第一条
+func (w *WechatNotify) enqueue(send *wechatSend, posts []*wechatPost) {
+	// 如果未配置 webhook URL，只记录日志
+	if w.webhookURL == "" {
+		zap.S().Error("未配置 webhook URL，仅记录日志")
+		send.pending = 1
+		w.finish(send, nil)
+		return
+	}
+
+	var queued []*wechatPost
+	for _, post := range posts {
+		limit := post.contentLimit()
+		if limit == 0 || len(post.content) <= limit {
+			queued = append(queued, post)
+			continue
+		}
+		parts := splitContent(post.content, limit)
+		zap.S().Infof("企业微信消息超过 %d 字节，拆分为 %d 条发送", limit, len(parts))
+		for i, part := range parts {
+			p := &wechatPost{msgType: post.msgType, content: part}
+			if i == 0 {
+				p.mentions, p.mobiles = post.mentions, post.mobiles
+			}
+			queued = append(queued, p)
+		}
+	}
+	for _, p := range queued {
+		p.send = send
+	}
+	send.pending = len(queued)
+
+	w.startOnce.Do(w.startWorker)
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		for range queued {
+			w.finish(send, errWechatStopped)
+		}
+		return
+	}
+	w.queue = append(w.queue, queued...)
+	w.mu.Unlock()
+
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start 启动后台发送协程，返回停止函数；未调用时在第一条消息加入队列时启动
+// 停止时队列中尚未发送的消息按发送失败处理，由发件箱保存并在重启后重试
+func (w *WechatNotify) Start() func() {
+	w.startOnce.Do(w.startWorker)
+	return w.stop
+}
+
+// startWorker 启动后台发送协程
+func (w *WechatNotify) startWorker() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	w.stop = func() {
+		cancel()
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		w.run(ctx)
+
+		w.mu.Lock()
+		queued := w.queue
+		w.queue = nil
+		w.stopped = true
+		w.mu.Unlock()
+		for _, p := range queued {
+			w.finish(p.send, errWechatStopped)
+		}
+	}()
+}
+
+// run 发送队列中的消息直到 ctx 取消
+// 有消息加入时先等待合并窗口；每次发送前取得发送令牌，等待期间加入队列的消息一并合并
+func (w *WechatNotify) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wakeup:
+		}
+		if w.mergeWindow > 0 {
+			if err := sleep(ctx, w.mergeWindow); err != nil {
+				return
+			}
+		}
+
+		for {
+			w.mu.Lock()
+			empty := len(w.queue) == 0
+			w.mu.Unlock()
+			if empty {
+				break
+			}
+
+			// 只有发送协程会取出队列中的消息，等待令牌期间队列不会变空
+			if err := w.bucket.wait(ctx); err != nil {
+				return
+			}
+			w.mu.Lock()
+			batch, merged := w.takeBatch()
+			w.mu.Unlock()
+
+			if len(batch) > 1 {
+				zap.S().Infof("合并 %d 条企业微信消息为一次发送", len(batch))
+			}
+			err := w.post(merged.payload())
+			for _, p := range batch {
+				w.finish(p.send, err)
+			}
+		}
+	}
+}
+
+// finish 记录企业微信消息的发送结果，通知消息全部发送后返回结果或交给 onFailure 处理
+func (w *WechatNotify) finish(send *wechatSend, err error) {
+	finished, err := send.complete(err)
+	if !finished {
+		return
+	}
+	switch {
+	case send.done != nil:
+		send.done <- err
+	case err != nil && w.onFailure != nil:
+		w.onFailure(send.msg, err)
+	case err != nil:
+		zap.S().Errorf("企业微信通知发送失败 [%s]: %v", w.name, err)
+	}
+}
+
+// sleep 等待 d 时长，ctx 取消时返回错误
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// takeBatch 从队列头部取出可以合并为一次发送的消息，返回取出的消息与合并后的消息，调用方需持有锁
+// 相邻的同类型文本或 markdown 消息在不超过内容上限时合并，模板卡片单独发送
+func (w *WechatNotify) takeBatch() ([]*wechatPost, *wechatPost) {
+	first := w.queue[0]
+	merged := &wechatPost{msgType: first.msgType, content: first.content, card: first.card, mentions: first.mentions, mobiles: first.mobiles}
+	n := 1
+	if limit := first.contentLimit(); limit > 0 {
+		for ; n < len(w.queue); n++ {
+			next := w.queue[n]
+			if next.msgType != merged.msgType || len(merged.content)+len(wechatMergeSeparator)+len(next.content) > limit {
+				break
+			}
+			merged.content += wechatMergeSeparator + next.content
+			merged.mentions = mergeLists(merged.mentions, next.mentions)
+			merged.mobiles = mergeLists(merged.mobiles, next.mobiles)
+		}
+	}
+
+	batch := w.queue[:n:n]
+	w.queue = w.queue[n:]
+	return batch, merged
+}
+
+// mergeLists 合并两个列表并去除重复项
+func mergeLists(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// splitContent 将内容拆分为不超过 limit 字节的多段
+// 优先在换行处拆分，单行过长时在 UTF-8 字符边界处拆分
+func splitContent(content string, limit int) []string {
+	var parts []string
+	for len(content) > limit {
+		cut := strings.LastIndex(content[:limit+1], "\n")
+		next := cut + 1
+		if cut <= 0 {
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(content[cut]) {
+				cut--
+			}
+			next = cut
+		}
+		parts = append(parts, content[:cut])
+		content = content[next:]
+	}
+	if content != "" {
+		parts = append(parts, content)
+	}
+	return parts
+}
+
+// tokenBucket 令牌桶，容量为 capacity，每 period 补满
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // 每秒补充的令牌数
+	last     time.Time
+}
+
+// newTokenBucket 创建令牌桶，每 period 最多取得 limit 个令牌
+func newTokenBucket(limit int, period time.Duration) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(limit),
+		tokens:   float64(limit),
+		rate:     float64(limit) / period.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+// reserve 尝试取得一个令牌，成功返回 0，否则返回需要等待的时间
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// wait 阻塞直到取得一个令牌，ctx 取消时返回错误
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		d := b.reserve(time.Now())
+		if d == 0 {
+			return nil
+		}
+		zap.S().Warnf("企业微信发送频率达到上限，等待 %s", d.Round(time.Millisecond))
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWechatMergeBurst(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	wechat := newWechatNotify(&config.Config{}, ChannelWecom, ts.URL, WechatMsgTypeText)
+	wechat.mergeWindow = 200 * time.Millisecond
+
+	// 合并窗口内的多条文本消息合并为一次发送，@ 提醒取并集
+	var wg sync.WaitGroup
+	for i, user := range []string{"zhangsan", "lisi", "zhangsan"} {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+			assert.NoError(t, wechat.SendToWechatMention(strings.Repeat("告警", i+1), []string{user}))
+		}(i, user)
+	}
+	wg.Wait()
+
+	require.Len(t, payloads, 1)
+	text := payloads[0]["text"].(map[string]any)
+	parts := strings.Split(text["content"].(string), "\n\n")
+	assert.ElementsMatch(t, []string{"告警", "告警告警", "告警告警告警"}, parts)
+	assert.ElementsMatch(t, []any{"zhangsan", "lisi"}, text["mentioned_list"])
+
+	// 超长消息按内容上限拆分，不与其他消息合并超过上限
+	payloads = nil
+	line := strings.Repeat("磁盘空间不足", 50) + "\n"
+	require.NoError(t, wechat.SendToWechatMention(strings.Repeat(line, 10), []string{"lisi"}))
+	require.Greater(t, len(payloads), 1)
+	for i, payload := range payloads {
+		text := payload["text"].(map[string]any)
+		content := text["content"].(string)
+		assert.LessOrEqual(t, len(content), WechatMaxTextBytes)
+		if i == 0 {
+			assert.Equal(t, []any{"lisi"}, text["mentioned_list"])
+		} else {
+			assert.Nil(t, text["mentioned_list"])
+		}
+	}
+}
+
+func TestSplitContent(t *testing.T) {
+	// 优先在换行处拆分
+	assert.Equal(t, []string{"aaaa", "bbbb", "cc"}, splitContent("aaaa\nbbbb\ncc", 6))
+
+	// 单行过长时在字符边界拆分，不截断多字节字符
+	content := strings.Repeat("告警", 10)
+	parts := splitContent(content, 10)
+	assert.Equal(t, content, strings.Join(parts, ""))
+	for _, part := range parts {
+		assert.LessOrEqual(t, len(part), 10)
+		assert.True(t, utf8.ValidString(part), part)
+	}
+
+	assert.Equal(t, []string{"短消息"}, splitContent("短消息", WechatMaxTextBytes))
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, time.Minute)
+	now := b.last
+
+	assert.Zero(t, b.reserve(now))
+	assert.Zero(t, b.reserve(now))
+	// 令牌用完，每 30 秒补充一个
+	assert.Equal(t, 30*time.Second, b.reserve(now))
+	assert.Equal(t, 10*time.Second, b.reserve(now.Add(20*time.Second)))
+	assert.Zero(t, b.reserve(now.Add(30*time.Second)))
+
+	// 空闲后最多累积 capacity 个令牌
+	later := now.Add(time.Hour)
+	assert.Zero(t, b.reserve(later))
+	assert.Zero(t, b.reserve(later))
+	assert.NotZero(t, b.reserve(later))
+}
+
+func TestWechatAsyncSend(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.Write([]byte(`{"errcode":45009,"errmsg":"api freq out of limit"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{}
+	cfg.Wechat.WebhookURL = ts.URL
+	cfg.Wechat.MergeWindow = 1
+	registry, err := NewRegistry(cfg)
+	require.NoError(t, err)
+	outbox, err := NewOutbox(cfg, nil, registry.sendTo)
+	require.NoError(t, err)
+	registry.useOutbox(outbox)
+	wechat := registry.Channels()[0].(*WechatNotify)
+	stop := wechat.Start()
+
+	// 只加入发送队列，不等待合并窗口与发送结果
+	start := time.Now()
+	require.NoError(t, registry.Send(&Message{Event: EventAlert, Content: "磁盘告警"}))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// 后台发送失败的消息加入发件箱，重试时同步发送并返回结果
+	require.Eventually(t, func() bool { return outbox.Pending() == 1 }, 3*time.Second, 10*time.Millisecond)
+	fail.Store(false)
+	assert.Equal(t, time.Duration(0), outbox.retry(time.Now().Add(time.Hour)))
+	assert.Equal(t, 0, outbox.Pending())
+
+	// 停止后的消息按发送失败处理
+	stop()
+	assert.ErrorIs(t, wechat.SendToWechat("test"), errWechatStopped)
+}
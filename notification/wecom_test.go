@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWechatRichMessages(t *testing.T) {
+	var payloads []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	msg := &Message{
+		Event: EventAlert,
+		Title: "📞 告警通知: 磁盘空间不足",
+		Fields: []Field{
+			{Name: "名称", Value: "2 条告警合并通知\n1. disk\n2. cpu"},
+			{Name: "电话号码", Value: "张三 13800138000"},
+		},
+		Note:           "即将开始拨打电话...",
+		Severity:       "critical",
+		URL:            "https://alert.example.com/jobs/1",
+		Mentions:       []string{"zhangsan"},
+		MentionMobiles: []string{"13900139000"},
+	}
+
+	// markdown：级别颜色、任务链接、<@userid> 提醒，手机号另发文本消息提醒
+	wechat := newWechatNotify(&config.Config{}, ChannelWecom, ts.URL, WechatMsgTypeMarkdown)
+	require.NoError(t, wechat.sendSync(msg))
+	require.Len(t, payloads, 2)
+	assert.Equal(t, "markdown", payloads[0]["msgtype"])
+	content := payloads[0]["markdown"].(map[string]any)["content"].(string)
+	assert.Contains(t, content, "### 📞 告警通知: 磁盘空间不足")
+	assert.Contains(t, content, `<font color="warning">🔴 严重</font>`)
+	assert.Contains(t, content, "> 名称: <font color=\"comment\">2 条告警合并通知\n> 1. disk\n> 2. cpu</font>")
+	assert.Contains(t, content, "[查看任务状态](https://alert.example.com/jobs/1)")
+	assert.Contains(t, content, "<@zhangsan>")
+	text := payloads[1]["text"].(map[string]any)
+	assert.Equal(t, []any{"13900139000"}, text["mentioned_mobile_list"])
+	assert.Nil(t, text["mentioned_list"])
+
+	// 模板卡片：级别标签与跳转链接，用户与手机号另发文本消息提醒
+	payloads = nil
+	wechat.msgType = WechatMsgTypeTemplateCard
+	require.NoError(t, wechat.sendSync(msg))
+	require.Len(t, payloads, 2)
+	card := payloads[0]["template_card"].(map[string]any)
+	assert.Equal(t, "text_notice", card["card_type"])
+	assert.Equal(t, "🔴 严重", card["emphasis_content"].(map[string]any)["title"])
+	assert.Equal(t, "https://alert.example.com/jobs/1", card["card_action"].(map[string]any)["url"])
+	items := card["horizontal_content_list"].([]any)
+	assert.Equal(t, "2 条告警合并通知 1. disk 2. cpu", items[0].(map[string]any)["value"])
+	text = payloads[1]["text"].(map[string]any)
+	assert.Equal(t, []any{"zhangsan"}, text["mentioned_list"])
+	assert.Equal(t, []any{"13900139000"}, text["mentioned_mobile_list"])
+
+	// 没有链接时模板卡片改为 markdown
+	payloads = nil
+	require.NoError(t, wechat.sendSync(&Message{Title: "✅ 告警已确认", Fields: []Field{{Name: "名称", Value: "disk"}}}))
+	require.Len(t, payloads, 1)
+	assert.Equal(t, "markdown", payloads[0]["msgtype"])
+
+	// 文本消息
+	payloads = nil
+	wechat.msgType = WechatMsgTypeText
+	require.NoError(t, wechat.sendSync(msg))
+	require.Len(t, payloads, 1)
+	text = payloads[0]["text"].(map[string]any)
+	assert.Equal(t, msg.Text(), text["content"])
+	assert.Equal(t, []any{"zhangsan"}, text["mentioned_list"])
+	assert.Equal(t, []any{"13900139000"}, text["mentioned_mobile_list"])
+
+	_, err := NewRegistry(&config.Config{Notifiers: []config.Notifier{{Type: ChannelWecom, WebhookURL: ts.URL, MsgType: "news"}}})
+	assert.Error(t, err)
+}
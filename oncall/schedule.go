@@ -0,0 +1,205 @@
+package oncall
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	// 内置时区数据，运行环境缺少 tzdata 时也能加载值班表时区
+	_ "time/tzdata"
+
+	"alert-mobile-notify/config"
+
+	"go.uber.org/fx"
+)
+
+const (
+	DefaultTimezone    = "Asia/Shanghai" // 默认时区
+	DefaultShiftDays   = 7               // 默认每班天数（每周轮换）
+	DefaultHandoffTime = "09:00"         // 默认交接时间
+
+	dateTimeLayout = "2006-01-02 15:04"
+)
+
+// Shift 一个值班班次
+type Shift struct {
+	Name     string    `json:"name"`
+	Phone    string    `json:"phone"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Override bool      `json:"override,omitempty"` // 是否为临时调整
+}
+
+// override 解析后的临时调整
+type override struct {
+	start, end time.Time
+	member     config.ScheduleMember
+}
+
+// Schedule 值班表
+type Schedule struct {
+	Name      string
+	loc       *time.Location
+	start     time.Time
+	shiftDays int
+	members   []config.ScheduleMember
+	overrides []override
+}
+
+// NewSchedule 根据配置创建值班表
+func NewSchedule(name string, cfg config.Schedule) (*Schedule, error) {
+	tz := cfg.Timezone
+	if tz == "" {
+		tz = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("值班表 %s 时区无效 [%s]: %w", name, tz, err)
+	}
+
+	if len(cfg.Members) == 0 {
+		return nil, fmt.Errorf("值班表 %s 未配置成员", name)
+	}
+	for _, m := range cfg.Members {
+		if strings.TrimSpace(m.Phone) == "" {
+			return nil, fmt.Errorf("值班表 %s 成员 %s 未配置电话", name, m.Name)
+		}
+	}
+
+	handoff := cfg.HandoffTime
+	if handoff == "" {
+		handoff = DefaultHandoffTime
+	}
+	start, err := time.ParseInLocation(dateTimeLayout, cfg.Start+" "+handoff, loc)
+	if err != nil {
+		return nil, fmt.Errorf("值班表 %s 起始日期或交接时间无效 [%s %s]: %w", name, cfg.Start, handoff, err)
+	}
+
+	shiftDays := cfg.ShiftDays
+	if shiftDays <= 0 {
+		shiftDays = DefaultShiftDays
+	}
+
+	s := &Schedule{Name: name, loc: loc, start: start, shiftDays: shiftDays, members: cfg.Members}
+	for i, o := range cfg.Overrides {
+		parsed, err := s.parseOverride(o)
+		if err != nil {
+			return nil, fmt.Errorf("值班表 %s 第 %d 条临时调整无效: %w", name, i+1, err)
+		}
+		s.overrides = append(s.overrides, parsed)
+	}
+	sort.Slice(s.overrides, func(i, j int) bool { return s.overrides[i].start.Before(s.overrides[j].start) })
+	return s, nil
+}
+
+// parseOverride 解析临时调整，值班人员为成员名称时使用成员电话
+func (s *Schedule) parseOverride(o config.ScheduleOverride) (override, error) {
+	start, err := time.ParseInLocation(dateTimeLayout, o.Start, s.loc)
+	if err != nil {
+		return override{}, fmt.Errorf("开始时间格式错误 [%s]: %w", o.Start, err)
+	}
+	end, err := time.ParseInLocation(dateTimeLayout, o.End, s.loc)
+	if err != nil {
+		return override{}, fmt.Errorf("结束时间格式错误 [%s]: %w", o.End, err)
+	}
+	if !end.After(start) {
+		return override{}, fmt.Errorf("结束时间需晚于开始时间")
+	}
+
+	member := config.ScheduleMember{Name: o.Name, Phone: o.Phone}
+	if member.Phone == "" {
+		for _, m := range s.members {
+			if m.Name == o.Name {
+				member.Phone = m.Phone
+				break
+			}
+		}
+	}
+	if member.Phone == "" {
+		return override{}, fmt.Errorf("值班人员 %s 未配置电话", o.Name)
+	}
+	return override{start: start, end: end, member: member}, nil
+}
+
+// At 返回 t 时刻的值班班次
+// 临时调整优先于轮换；轮换班次被临时调整截断时，返回的班次只包含未被覆盖的部分
+func (s *Schedule) At(t time.Time) *Shift {
+	t = t.In(s.loc)
+	for _, o := range s.overrides {
+		if !t.Before(o.start) && t.Before(o.end) {
+			return &Shift{Name: o.member.Name, Phone: o.member.Phone, Start: o.start, End: o.end, Override: true}
+		}
+	}
+
+	index, start, end := s.rotationAt(t)
+	// 轮换班次的起止时间以相邻的临时调整为界（临时调整已按开始时间排序）
+	for _, o := range s.overrides {
+		if o.end.After(start) && !o.end.After(t) {
+			start = o.end
+		}
+		if o.start.After(t) && o.start.Before(end) {
+			end = o.start
+			break
+		}
+	}
+
+	m := s.members[index]
+	return &Shift{Name: m.Name, Phone: m.Phone, Start: start, End: end}
+}
+
+// Next 返回 t 时刻之后的下一个值班班次
+func (s *Schedule) Next(t time.Time) *Shift {
+	return s.At(s.At(t).End)
+}
+
+// rotationAt 计算 t 时刻轮换到的成员序号与该班次的起止时间
+// 按日历天数推算交接时间，避免夏令时切换导致交接时间偏移
+func (s *Schedule) rotationAt(t time.Time) (int, time.Time, time.Time) {
+	shift := time.Duration(s.shiftDays) * 24 * time.Hour
+	k := int(t.Sub(s.start) / shift)
+	if t.Before(s.start) {
+		k--
+	}
+
+	boundary := func(k int) time.Time { return s.start.AddDate(0, 0, k*s.shiftDays) }
+	for boundary(k).After(t) {
+		k--
+	}
+	for !boundary(k + 1).After(t) {
+		k++
+	}
+
+	n := len(s.members)
+	return ((k % n) + n) % n, boundary(k), boundary(k + 1)
+}
+
+// Schedules 按名称索引的值班表
+type Schedules map[string]*Schedule
+
+// NewSchedules 根据配置创建全部值班表，配置无效时返回错误
+func NewSchedules(cfg *config.Config) (Schedules, error) {
+	schedules := make(Schedules, len(cfg.Schedules))
+	for name, sc := range cfg.Schedules {
+		schedule, err := NewSchedule(name, sc)
+		if err != nil {
+			return nil, err
+		}
+		schedules[name] = schedule
+	}
+	return schedules, nil
+}
+
+// Names 返回按名称排序的值班表名称
+func (s Schedules) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProvideSchedules 提供值班表依赖注入
+func ProvideSchedules() fx.Option {
+	return fx.Provide(NewSchedules)
+}
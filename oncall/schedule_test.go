@@ -0,0 +1,103 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+
+	"alert-mobile-notify/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSchedule(t *testing.T) *Schedule {
+	schedule, err := NewSchedule("ops", config.Schedule{
+		Timezone:    "Asia/Shanghai",
+		Start:       "2026-01-05",
+		ShiftDays:   7,
+		HandoffTime: "09:00",
+		Members: []config.ScheduleMember{
+			{Name: "张三", Phone: "13800138000"},
+			{Name: "李四", Phone: "13900139000"},
+			{Name: "王五", Phone: "13700137000"},
+		},
+		Overrides: []config.ScheduleOverride{
+			{Start: "2026-01-14 18:00", End: "2026-01-15 09:00", Name: "王五"},
+			{Start: "2026-01-16 00:00", End: "2026-01-17 00:00", Name: "赵六", Phone: "13600136000"},
+		},
+	})
+	require.NoError(t, err)
+	return schedule
+}
+
+func TestSchedule_Rotation(t *testing.T) {
+	schedule := newTestSchedule(t)
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		require.NoError(t, err)
+		return tm
+	}
+
+	shift := schedule.At(at("2026-01-05 09:00"))
+	assert.Equal(t, "张三", shift.Name)
+	assert.True(t, shift.End.Equal(at("2026-01-12 09:00")))
+
+	// 交接时间前仍为上一班
+	assert.Equal(t, "张三", schedule.At(at("2026-01-12 08:59")).Name)
+	assert.Equal(t, "李四", schedule.At(at("2026-01-12 09:00")).Name)
+	// 轮换一圈后回到第一位，起始日期前按倒序推算
+	assert.Equal(t, "张三", schedule.At(at("2026-01-26 10:00")).Name)
+	assert.Equal(t, "王五", schedule.At(at("2026-01-01 10:00")).Name)
+
+	// 临时调整优先，轮换班次以临时调整为界
+	shift = schedule.At(at("2026-01-14 20:00"))
+	assert.Equal(t, "王五", shift.Name)
+	assert.Equal(t, "13700137000", shift.Phone)
+	assert.True(t, shift.Override)
+
+	shift = schedule.At(at("2026-01-13 10:00"))
+	assert.Equal(t, "李四", shift.Name)
+	assert.True(t, shift.End.Equal(at("2026-01-14 18:00")))
+
+	shift = schedule.At(at("2026-01-15 10:00"))
+	assert.Equal(t, "李四", shift.Name)
+	assert.True(t, shift.Start.Equal(at("2026-01-15 09:00")))
+	assert.True(t, shift.End.Equal(at("2026-01-16 00:00")))
+
+	next := schedule.Next(at("2026-01-15 10:00"))
+	assert.Equal(t, "赵六", next.Name)
+	assert.Equal(t, "13600136000", next.Phone)
+	assert.Equal(t, "李四", schedule.Next(at("2026-01-16 10:00")).Name)
+}
+
+func TestNewSchedule_Invalid(t *testing.T) {
+	members := []config.ScheduleMember{{Name: "张三", Phone: "13800138000"}}
+
+	_, err := NewSchedule("ops", config.Schedule{Start: "2026-01-05"})
+	assert.Error(t, err)
+	_, err = NewSchedule("ops", config.Schedule{Start: "bad", Members: members})
+	assert.Error(t, err)
+	_, err = NewSchedule("ops", config.Schedule{Start: "2026-01-05", Timezone: "Mars/Base", Members: members})
+	assert.Error(t, err)
+	_, err = NewSchedule("ops", config.Schedule{Start: "2026-01-05", Members: members, Overrides: []config.ScheduleOverride{
+		{Start: "2026-01-06 09:00", End: "2026-01-07 09:00", Name: "未知"},
+	}})
+	assert.Error(t, err)
+
+	schedules, err := NewSchedules(&config.Config{Schedules: map[string]config.Schedule{
+		"b": {Start: "2026-01-05", Members: members},
+		"a": {Start: "2026-01-05", Members: members},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, schedules.Names())
+}
+
+func TestNewSchedules_ExampleConfig(t *testing.T) {
+	cfg, err := config.LoadConfig("../config.yaml")
+	require.NoError(t, err)
+
+	schedules, err := NewSchedules(cfg)
+	require.NoError(t, err)
+	assert.NotEmpty(t, schedules.Names())
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"alert-mobile-notify/config"
+)
+
+const (
+	// DefaultDataDir 默认本地数据目录
+	DefaultDataDir = "data"
+)
+
+// DataDir 返回配置的数据目录，未配置时使用默认目录
+func DataDir(cfg *config.Config) string {
+	if cfg.Storage.DataDir != "" {
+		return cfg.Storage.DataDir
+	}
+	return DefaultDataDir
+}
+
+// JSONFile 以 JSON 格式持久化数据的本地文件
+// 写入时先写临时文件再原子替换，避免进程异常退出导致文件损坏
+type JSONFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewJSONFile 在数据目录下创建 JSON 文件存储，目录不存在时自动创建
+func NewJSONFile(dataDir, name string) (*JSONFile, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败 [%s]: %w", dataDir, err)
+	}
+	return &JSONFile{path: filepath.Join(dataDir, name)}, nil
+}
+
+// Path 返回文件路径
+func (f *JSONFile) Path() string {
+	return f.path
+}
+
+// Load 读取文件内容到 v，文件不存在时返回 false
+func (f *JSONFile) Load(v any) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取数据文件失败 [%s]: %w", f.path, err)
+	}
+	if len(data) == 0 {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("解析数据文件失败 [%s]: %w", f.path, err)
+	}
+	return true, nil
+}
+
+// Save 将 v 序列化后原子写入文件
+func (f *JSONFile) Save(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入数据文件失败 [%s]: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("替换数据文件失败 [%s]: %w", f.path, err)
+	}
+	return nil
+}